@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hilltracer/gomigrator/internal/logger"
+	"github.com/hilltracer/gomigrator/pkg/gomigrator"
+)
+
+// dbCommand is a command that needs a connection to the database.
+type dbCommand struct {
+	readOnly bool // changes nothing, so --dry-run has nothing to roll back
+	run      func(s *session) int
+}
+
+var dbCommands = map[string]dbCommand{
+	"status":    {readOnly: true, run: (*session).status},
+	"history":   {readOnly: true, run: (*session).history},
+	"validate":  {readOnly: true, run: (*session).validate},
+	"plan":      {readOnly: true, run: (*session).plan},
+	"dbversion": {readOnly: true, run: (*session).dbVersion},
+	"up":        {run: (*session).up},
+	"up-by-one": {run: (*session).upByOne},
+	"up-to":     {run: (*session).upTo},
+	"baseline":  {run: (*session).baseline},
+	"force":     {run: (*session).force},
+	"repair":    {run: (*session).repair},
+	"down":      {run: (*session).down},
+	"down-to":   {run: (*session).downTo},
+	"reset":     {run: (*session).reset},
+	"redo":      {run: (*session).redo},
+}
+
+// session is what a dbCommand runs with.
+type session struct {
+	dbArgs
+	ctx  context.Context
+	mig  *gomigrator.Migrator
+	p    *printer
+	logg *logger.Logger
+}
+
+// Reports a command that changed the database, with the version it left.
+func (s *session) changed(touched []gomigrator.Step, msg string) int {
+	if msg != "" {
+		s.logg.Info(msg)
+	}
+	res := changeOut{Steps: stepsOut(touched)}
+	if s.p.structured() {
+		v, err := s.mig.DBVersion(s.ctx)
+		if err != nil {
+			return s.p.fail(err)
+		}
+		res.Version = v
+	}
+	return s.p.ok(res, nil)
+}
+
+func (s *session) status() int {
+	statuses, err := s.mig.Status(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	return s.p.ok(statusesOut(statuses), func() {
+		if len(statuses) == 0 {
+			s.logg.Info("no migrations found")
+			return
+		}
+		printStatusTable(os.Stdout, statuses, useColor(os.Stdout))
+	})
+}
+
+func (s *session) history() int {
+	history, err := s.mig.History(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	return s.p.ok(historyEntriesOut(history), func() {
+		if len(history) == 0 {
+			s.logg.Info("no history recorded")
+		}
+		for _, h := range history {
+			fmt.Printf("%-25s %-9s %-5s %-14d %-8s %s@%s %s %s\n",
+				h.At.Format(time.RFC3339), h.Kind, h.Direction, h.Version,
+				h.Duration.Round(time.Millisecond), h.OSUser, h.Hostname, h.ToolVersion, h.Name)
+		}
+	})
+}
+
+func (s *session) validate() int {
+	drifts, err := s.mig.Validate(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	if !s.p.structured() {
+		for _, d := range drifts {
+			fmt.Printf("%-14d %s: checksum %s, recorded %s\n",
+				d.Version, d.Path, d.Actual, d.Recorded)
+		}
+	}
+	if len(drifts) > 0 {
+		return s.p.failWith(driftsOut(drifts), fmt.Errorf("%w: %d applied migration(s) changed on disk",
+			gomigrator.ErrChecksumDrift, len(drifts)))
+	}
+	s.logg.Info("all applied migrations match their checksums")
+	return s.p.ok(driftsOut(drifts), nil)
+}
+
+func (s *session) plan() int {
+	plan, err := s.mig.Plan(s.ctx, gomigrator.Command(s.planCmd), s.target)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	return s.p.ok(plansOut(plan), func() {
+		if len(plan) == 0 {
+			s.logg.Info("nothing to do")
+		}
+		for _, step := range plan {
+			fmt.Printf("-- %s %d_%s", step.Direction, step.Version, step.Name)
+			if step.NoTransaction {
+				fmt.Print(" (no transaction)")
+			}
+			fmt.Printf("\n%s\n\n", step.SQL)
+		}
+	})
+}
+
+func (s *session) dbVersion() int {
+	v, err := s.mig.DBVersion(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	return s.p.ok(map[string]int64{"version": v}, func() { fmt.Println(v) })
+}
+
+func (s *session) up() int {
+	touched, err := s.mig.Up(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	logSteps(s.logg, touched)
+	return s.changed(touched, "migrations applied")
+}
+
+func (s *session) upByOne() int {
+	touched, err := s.mig.UpByOne(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	if len(touched) == 0 {
+		s.logg.Info("no pending migrations")
+	}
+	logSteps(s.logg, touched)
+	return s.changed(touched, "")
+}
+
+func (s *session) upTo() int {
+	touched, err := s.mig.UpTo(s.ctx, s.target)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	logSteps(s.logg, touched)
+	return s.changed(touched, fmt.Sprintf("migrated up to %d", s.target))
+}
+
+func (s *session) baseline() int {
+	recorded, err := s.mig.Baseline(s.ctx, s.target)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	for _, step := range recorded {
+		s.logg.Info(fmt.Sprintf("baselined %d_%s", step.Version, step.Name))
+	}
+	return s.changed(recorded,
+		fmt.Sprintf("baseline at %d: %d migration(s) recorded as applied", s.target, len(recorded)))
+}
+
+func (s *session) force() int {
+	state := "applied"
+	if !s.forceApplied {
+		state = "unapplied"
+	}
+	if !confirm(fmt.Sprintf("Mark version %d %s without running any SQL?", s.target, state)) {
+		return s.p.fail(codedError{codeAborted, errors.New("aborted")})
+	}
+	touched, err := s.mig.Force(s.ctx, s.target, s.forceApplied)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	if len(touched) == 0 && s.forceApplied {
+		return s.changed(nil, fmt.Sprintf("version %d is already applied; record left as is", s.target))
+	}
+	if len(touched) == 0 {
+		return s.changed(nil, fmt.Sprintf("version %d has no record; nothing to do", s.target))
+	}
+	return s.changed(touched, fmt.Sprintf("forced version %d %s", s.target, state))
+}
+
+func (s *session) repair() int {
+	plan, err := s.mig.RepairPlan(s.ctx)
+	if errors.Is(err, gomigrator.ErrRepairRemovesAll) {
+		err = fmt.Errorf("%w; check --dir, or pass --allow-remove-all to remove them", err)
+	}
+	if err != nil {
+		return s.p.fail(err)
+	}
+	if len(plan.Removed) == 0 && len(plan.Updated) == 0 {
+		s.logg.Info("nothing to repair")
+		return s.p.ok(repairReportOut(plan), nil)
+	}
+	for _, e := range plan.Removed {
+		s.logg.Info(fmt.Sprintf("remove record %d_%s: file not found", e.Version, e.Name))
+	}
+	for _, d := range plan.Updated {
+		s.logg.Info(fmt.Sprintf("update checksum of %d_%s", d.Version, d.Name))
+	}
+	if !confirm("Apply these changes to the migration history?") {
+		return s.p.failWith(repairReportOut(plan), codedError{codeAborted, errors.New("aborted")})
+	}
+	report, err := s.mig.Repair(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	s.logg.Info(fmt.Sprintf("repaired: %d record(s) removed, %d checksum(s) updated",
+		len(report.Removed), len(report.Updated)))
+	return s.p.ok(repairReportOut(report), nil)
+}
+
+func (s *session) down() int {
+	if s.steps > 0 {
+		touched, err := s.mig.DownN(s.ctx, s.steps)
+		if err != nil {
+			return s.p.fail(err)
+		}
+		logSteps(s.logg, touched)
+		return s.changed(touched, "")
+	}
+	touched, err := s.mig.Down(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	return s.changed(touched, "migration rolled back")
+}
+
+func (s *session) downTo() int {
+	touched, err := s.mig.DownTo(s.ctx, s.target)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	logSteps(s.logg, touched)
+	return s.changed(touched, fmt.Sprintf("migrated down to %d", s.target))
+}
+
+func (s *session) reset() int {
+	touched, err := s.mig.Reset(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	logSteps(s.logg, touched)
+	return s.changed(touched, "all migrations rolled back")
+}
+
+func (s *session) redo() int {
+	touched, err := s.mig.Redo(s.ctx)
+	if err != nil {
+		return s.p.fail(err)
+	}
+	return s.changed(touched, "migration redone")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDBArgs_Plan(t *testing.T) {
+	a, err := parseDBArgs("plan", nil)
+	require.NoError(t, err)
+	require.Equal(t, "up", a.planCmd)
+
+	a, err = parseDBArgs("plan", []string{"down-to", "3"})
+	require.NoError(t, err)
+	require.Equal(t, dbArgs{planCmd: "down-to", target: 3, forceApplied: true}, a)
+
+	_, err = parseDBArgs("plan", []string{"bogus"})
+	require.Error(t, err)
+	require.Equal(t, codeUsage, errorCode(err))
+
+	_, err = parseDBArgs("plan", []string{"up-to"})
+	require.Equal(t, codeUsage, errorCode(err))
+}
+
+func TestPrintHelp_Envelope(t *testing.T) {
+	var buf bytes.Buffer
+	p := &printer{format: outputJSON, cmd: "help", out: &buf}
+	require.Equal(t, 0, printHelp(p))
+
+	var env struct {
+		Command string            `json:"command"`
+		OK      bool              `json:"ok"`
+		Result  map[string]string `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &env))
+	require.Equal(t, "help", env.Command)
+	require.True(t, env.OK)
+	require.Contains(t, env.Result["usage"], "Usage:")
+}
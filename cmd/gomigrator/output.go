@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hilltracer/gomigrator/internal/logger"
+	"github.com/hilltracer/gomigrator/pkg/gomigrator"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by --output.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// Error codes of structured output; scripts match on these, not on messages.
+const (
+	codeError             = "error"
+	codeUsage             = "usage"
+	codeConfig            = "config"
+	codeConnect           = "connect"
+	codeAborted           = "aborted"
+	codeLockTimeout       = "lock_timeout"
+	codeChecksumDrift     = "checksum_drift"
+	codeMissingMigrations = "missing_migrations"
+	codeMetaTooNew        = "meta_too_new"
+	codeRepairRemovesAll  = "repair_removes_all"
+)
+
+// codedError tags err with the code structured output reports for it.
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e codedError) Error() string { return e.err.Error() }
+func (e codedError) Unwrap() error { return e.err }
+
+func usageError(msg string) error { return codedError{codeUsage, errors.New(msg)} }
+
+// Returns the code reported for err: the library's sentinel errors first,
+// then the code the CLI tagged it with.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, gomigrator.ErrLockTimeout):
+		return codeLockTimeout
+	case errors.Is(err, gomigrator.ErrChecksumDrift):
+		return codeChecksumDrift
+	case errors.Is(err, gomigrator.ErrMissingMigrations):
+		return codeMissingMigrations
+	case errors.Is(err, gomigrator.ErrMetaTooNew):
+		return codeMetaTooNew
+	case errors.Is(err, gomigrator.ErrRepairRemovesAll):
+		return codeRepairRemovesAll
+	}
+	var ce codedError
+	if errors.As(err, &ce) {
+		return ce.code
+	}
+	return codeError
+}
+
+// envelope is the single document written to stdout with --output json|yaml.
+type envelope struct {
+	Command string    `json:"command"           yaml:"command"`
+	OK      bool      `json:"ok"                yaml:"ok"`
+	DryRun  bool      `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+	Result  any       `json:"result,omitempty"  yaml:"result,omitempty"`
+	Error   *cmdError `json:"error,omitempty"   yaml:"error,omitempty"`
+}
+
+type cmdError struct {
+	Code    string `json:"code"    yaml:"code"`
+	Message string `json:"message" yaml:"message"`
+	// migrations committed before the failure (see gomigrator.PartialError)
+	Committed []stepOut `json:"committed,omitempty" yaml:"committed,omitempty"`
+}
+
+// printer reports how a command went: as text for --output table, or as one
+// envelope on stdout for json and yaml. Logs always go to stderr.
+type printer struct {
+	format string
+	cmd    string
+	out    io.Writer      // where envelopes go, normally stdout
+	logg   *logger.Logger // nil until the config is loaded
+}
+
+func validOutput(format string) bool {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return true
+	}
+	return false
+}
+
+func (p *printer) structured() bool { return p.format != outputTable }
+
+// Reports success. table prints the text form and only runs for --output table.
+func (p *printer) ok(result any, table func()) int {
+	if !p.structured() {
+		if table != nil {
+			table()
+		}
+		return 0
+	}
+	p.emit(envelope{Command: p.cmd, OK: true, DryRun: dryRun, Result: result})
+	return 0
+}
+
+// Reports err and returns the exit status.
+func (p *printer) fail(err error) int { return p.failWith(nil, err) }
+
+// Like fail, but structured output also carries result.
+func (p *printer) failWith(result any, err error) int {
+	if !p.structured() {
+		if p.logg == nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			p.logg.Error(err.Error())
+		}
+		return 1
+	}
+	e := &cmdError{Code: errorCode(err), Message: err.Error()}
+	var pe *gomigrator.PartialError
+	if errors.As(err, &pe) {
+		e.Committed = stepsOut(pe.Committed)
+	}
+	p.emit(envelope{Command: p.cmd, DryRun: dryRun, Result: result, Error: e})
+	return 1
+}
+
+func (p *printer) emit(doc envelope) {
+	var err error
+	switch p.format {
+	case outputJSON:
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(doc)
+	case outputYAML:
+		enc := yaml.NewEncoder(p.out)
+		enc.SetIndent(2)
+		if err = enc.Encode(doc); err == nil {
+			err = enc.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "write output:", err)
+	}
+}
+
+// Result types of structured output. Durations are in milliseconds and
+// timestamps in RFC 3339; missing values are omitted.
+
+type statusOut struct {
+	Version     int64      `json:"version"                yaml:"version"`
+	Name        string     `json:"name"                   yaml:"name"`
+	State       string     `json:"state"                  yaml:"state"`
+	Path        string     `json:"path,omitempty"         yaml:"path,omitempty"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"   yaml:"applied_at,omitempty"`
+	DurationMs  *int64     `json:"duration_ms,omitempty"  yaml:"duration_ms,omitempty"`
+	AppliedBy   string     `json:"applied_by,omitempty"   yaml:"applied_by,omitempty"`
+	ToolVersion string     `json:"tool_version,omitempty" yaml:"tool_version,omitempty"`
+}
+
+type stepOut struct {
+	Version   int64  `json:"version"   yaml:"version"`
+	Name      string `json:"name"      yaml:"name"`
+	Direction string `json:"direction" yaml:"direction"`
+}
+
+type planOut struct {
+	Version       int64  `json:"version"                  yaml:"version"`
+	Name          string `json:"name"                     yaml:"name"`
+	Direction     string `json:"direction"                yaml:"direction"`
+	NoTransaction bool   `json:"no_transaction,omitempty" yaml:"no_transaction,omitempty"`
+	SQL           string `json:"sql"                      yaml:"sql"`
+}
+
+type historyOut struct {
+	ID          int64     `json:"id"                     yaml:"id"`
+	Version     int64     `json:"version"                yaml:"version"`
+	Name        string    `json:"name"                   yaml:"name"`
+	Kind        string    `json:"kind"                   yaml:"kind"`
+	Direction   string    `json:"direction"              yaml:"direction"`
+	Checksum    string    `json:"checksum,omitempty"     yaml:"checksum,omitempty"`
+	DurationMs  int64     `json:"duration_ms"            yaml:"duration_ms"`
+	OSUser      string    `json:"os_user,omitempty"      yaml:"os_user,omitempty"`
+	Hostname    string    `json:"hostname,omitempty"     yaml:"hostname,omitempty"`
+	ToolVersion string    `json:"tool_version,omitempty" yaml:"tool_version,omitempty"`
+	At          time.Time `json:"at"                     yaml:"at"`
+}
+
+type driftOut struct {
+	Version  int64  `json:"version"  yaml:"version"`
+	Name     string `json:"name"     yaml:"name"`
+	Path     string `json:"path"     yaml:"path"`
+	Recorded string `json:"recorded" yaml:"recorded"`
+	Actual   string `json:"actual"   yaml:"actual"`
+}
+
+type repairOut struct {
+	Removed []statusOut `json:"removed" yaml:"removed"`
+	Updated []driftOut  `json:"updated" yaml:"updated"`
+}
+
+// Result of the commands that change the database.
+type changeOut struct {
+	Version int64     `json:"version"         yaml:"version"` // DB version afterwards
+	Steps   []stepOut `json:"steps,omitempty" yaml:"steps,omitempty"`
+}
+
+type versionOut struct {
+	Version   string `json:"version"              yaml:"version"`
+	GitHash   string `json:"git_hash,omitempty"   yaml:"git_hash,omitempty"`
+	BuildDate string `json:"build_date,omitempty" yaml:"build_date,omitempty"`
+}
+
+func statusesOut(entries []gomigrator.StatusEntry) []statusOut {
+	res := make([]statusOut, len(entries))
+	for i, s := range entries {
+		res[i] = statusOut{
+			Version:     s.Version,
+			Name:        s.Name,
+			State:       string(s.State),
+			Path:        s.Path,
+			AppliedBy:   s.AppliedBy,
+			ToolVersion: s.ToolVersion,
+		}
+		if !s.AppliedAt.IsZero() {
+			res[i].AppliedAt = &s.AppliedAt
+		}
+		if s.HasRunInfo {
+			ms := s.Duration.Milliseconds()
+			res[i].DurationMs = &ms
+		}
+	}
+	return res
+}
+
+func stepsOut(steps []gomigrator.Step) []stepOut {
+	res := make([]stepOut, len(steps))
+	for i, s := range steps {
+		res[i] = stepOut{Version: s.Version, Name: s.Name, Direction: string(s.Direction)}
+	}
+	return res
+}
+
+func plansOut(plan []gomigrator.PlanStep) []planOut {
+	res := make([]planOut, len(plan))
+	for i, p := range plan {
+		res[i] = planOut{
+			Version:       p.Version,
+			Name:          p.Name,
+			Direction:     string(p.Direction),
+			NoTransaction: p.NoTransaction,
+			SQL:           p.SQL,
+		}
+	}
+	return res
+}
+
+func historyEntriesOut(history []gomigrator.HistoryEntry) []historyOut {
+	res := make([]historyOut, len(history))
+	for i, h := range history {
+		res[i] = historyOut{
+			ID:          h.ID,
+			Version:     h.Version,
+			Name:        h.Name,
+			Kind:        string(h.Kind),
+			Direction:   string(h.Direction),
+			Checksum:    h.Checksum,
+			DurationMs:  h.Duration.Milliseconds(),
+			OSUser:      h.OSUser,
+			Hostname:    h.Hostname,
+			ToolVersion: h.ToolVersion,
+			At:          h.At,
+		}
+	}
+	return res
+}
+
+func driftsOut(drifts []gomigrator.Drift) []driftOut {
+	res := make([]driftOut, len(drifts))
+	for i, d := range drifts {
+		res[i] = driftOut{Version: d.Version, Name: d.Name, Path: d.Path, Recorded: d.Recorded, Actual: d.Actual}
+	}
+	return res
+}
+
+func repairReportOut(r gomigrator.RepairReport) repairOut {
+	return repairOut{Removed: statusesOut(r.Removed), Updated: driftsOut(r.Updated)}
+}
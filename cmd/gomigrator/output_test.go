@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hilltracer/gomigrator/pkg/gomigrator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCode(t *testing.T) {
+	boom := errors.New("boom")
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("up: %w", gomigrator.ErrLockTimeout), codeLockTimeout},
+		{fmt.Errorf("up: %w", gomigrator.ErrChecksumDrift), codeChecksumDrift},
+		{fmt.Errorf("up: %w", gomigrator.ErrMissingMigrations), codeMissingMigrations},
+		{fmt.Errorf("connect: %w", gomigrator.ErrMetaTooNew), codeMetaTooNew},
+		{fmt.Errorf("repair: %w", gomigrator.ErrRepairRemovesAll), codeRepairRemovesAll},
+		{usageError("unknown command: upp"), codeUsage},
+		{fmt.Errorf("wrapped: %w", codedError{codeConnect, boom}), codeConnect},
+		// the library's sentinel wins over the CLI's tag
+		{codedError{codeConnect, gomigrator.ErrMetaTooNew}, codeMetaTooNew},
+		{boom, codeError},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.want, errorCode(c.err), c.err.Error())
+	}
+}
+
+func TestPrinter_OKEnvelope(t *testing.T) {
+	var buf bytes.Buffer
+	p := &printer{format: outputJSON, cmd: "up", out: &buf}
+
+	steps := []gomigrator.Step{{Version: 1, Name: "init", Direction: gomigrator.DirectionUp}}
+	require.Equal(t, 0, p.ok(changeOut{Version: 1, Steps: stepsOut(steps)}, func() {
+		t.Fatal("table output with --output json")
+	}))
+	require.JSONEq(t, `{
+		"command": "up",
+		"ok": true,
+		"result": {"version": 1, "steps": [{"version": 1, "name": "init", "direction": "up"}]}
+	}`, buf.String())
+}
+
+func TestPrinter_FailReportsCommittedSteps(t *testing.T) {
+	var buf bytes.Buffer
+	p := &printer{format: outputJSON, cmd: "up", out: &buf}
+
+	err := &gomigrator.PartialError{
+		Committed: []gomigrator.Step{{Version: 1, Name: "init", Direction: gomigrator.DirectionUp}},
+		Err:       errors.New("apply 2_users: boom"),
+	}
+	require.Equal(t, 1, p.fail(err))
+	require.JSONEq(t, `{
+		"command": "up",
+		"ok": false,
+		"error": {
+			"code": "error",
+			"message": "apply 2_users: boom (committed before failure: up 1_init)",
+			"committed": [{"version": 1, "name": "init", "direction": "up"}]
+		}
+	}`, buf.String())
+}
+
+func TestPrinter_YAMLEnvelope(t *testing.T) {
+	var buf bytes.Buffer
+	p := &printer{format: outputYAML, cmd: "dbversion", out: &buf}
+
+	require.Equal(t, 1, p.fail(usageError("usage: gomigrator [flags] [DSN] up-to <version>")))
+	require.Equal(t, `command: dbversion
+ok: false
+error:
+  code: usage
+  message: 'usage: gomigrator [flags] [DSN] up-to <version>'
+`, buf.String())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hilltracer/gomigrator/pkg/gomigrator"
+)
+
+// ANSI colors of the status table.
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorPurple = "\x1b[35m"
+)
+
+// Reports whether f is a terminal that should get colors; NO_COLOR turns
+// them off (https://no-color.org).
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Label and color of a state in the status table.
+func stateStyle(s gomigrator.State) (string, string) {
+	switch s {
+	case gomigrator.StateApplied:
+		return "applied", colorGreen
+	case gomigrator.StatePending:
+		return "pending", colorYellow
+	case gomigrator.StateMissingFile:
+		return "missing", colorRed
+	case gomigrator.StateDrifted:
+		return "drifted", colorPurple
+	}
+	return string(s), ""
+}
+
+// Writes statuses as an aligned table followed by a summary line.
+func printStatusTable(w io.Writer, statuses []gomigrator.StatusEntry, color bool) {
+	paint := func(code, s string) string {
+		if !color || code == "" {
+			return s
+		}
+		return code + s + colorReset
+	}
+
+	header := []string{"VERSION", "NAME", "STATE", "APPLIED AT", "DURATION", "APPLIED BY", "TOOL VERSION"}
+	rows := make([][]string, len(statuses))
+	colors := make([]string, len(statuses))
+	counts := make(map[gomigrator.State]int)
+	var current int64
+	for i, s := range statuses {
+		appliedAt, took := "-", "-"
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.Local().Format(time.DateTime)
+		}
+		if s.HasRunInfo {
+			took = s.Duration.Round(time.Millisecond).String()
+		}
+		label, code := stateStyle(s.State)
+		rows[i] = []string{
+			fmt.Sprint(s.Version), s.Name, label, appliedAt, took,
+			cmp.Or(s.AppliedBy, "-"), cmp.Or(s.ToolVersion, "-"),
+		}
+		colors[i] = code
+
+		counts[s.State]++
+		if s.IsApplied && s.Version > current {
+			current = s.Version
+		}
+	}
+
+	widths := make([]int, len(header))
+	for _, r := range append([][]string{header}, rows...) {
+		for c, cell := range r {
+			widths[c] = max(widths[c], len(cell))
+		}
+	}
+	// pads every cell but the last one; colors go around the padded text
+	// so they do not count towards the width
+	line := func(cells []string, stateColor string) string {
+		var b strings.Builder
+		for c, cell := range cells {
+			if c < len(cells)-1 {
+				cell = fmt.Sprintf("%-*s", widths[c], cell)
+			}
+			if c == 2 {
+				cell = paint(stateColor, cell)
+			}
+			if c > 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(cell)
+		}
+		return strings.TrimRight(b.String(), " ")
+	}
+
+	fmt.Fprintln(w, paint(colorBold, line(header, "")))
+	for i, r := range rows {
+		fmt.Fprintln(w, line(r, colors[i]))
+	}
+
+	summary := []string{
+		fmt.Sprintf("%d applied", counts[gomigrator.StateApplied]),
+		fmt.Sprintf("%d pending", counts[gomigrator.StatePending]),
+	}
+	if n := counts[gomigrator.StateDrifted]; n > 0 {
+		summary = append(summary, paint(colorPurple, fmt.Sprintf("%d drifted", n)))
+	}
+	if n := counts[gomigrator.StateMissingFile]; n > 0 {
+		summary = append(summary, paint(colorRed, fmt.Sprintf("%d missing", n)))
+	}
+	summary = append(summary, fmt.Sprintf("current version %d", current))
+	fmt.Fprintf(w, "\n%s\n", strings.Join(summary, ", "))
+}
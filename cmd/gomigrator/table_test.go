@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hilltracer/gomigrator/pkg/gomigrator"
+	"github.com/stretchr/testify/require"
+)
+
+func statusFixture() []gomigrator.StatusEntry {
+	at := time.Date(2025, 8, 1, 12, 0, 0, 0, time.Local)
+	return []gomigrator.StatusEntry{
+		{
+			Version: 1, Name: "init", State: gomigrator.StateApplied, IsApplied: true, AppliedAt: at,
+			HasRunInfo: true, Duration: 2 * time.Millisecond, AppliedBy: "ci@build", ToolVersion: "v1.4.0",
+		},
+		// faster than 1ms: recorded as 0, unlike the row from an older release below
+		{
+			Version: 2, Name: "users", State: gomigrator.StateDrifted, IsApplied: true, AppliedAt: at,
+			HasRunInfo: true, AppliedBy: "ci@build", ToolVersion: "v1.4.0",
+		},
+		{Version: 3, Name: "gone", State: gomigrator.StateMissingFile, IsApplied: true, AppliedAt: at},
+		{Version: 4, Name: "orders", State: gomigrator.StatePending},
+	}
+}
+
+func TestPrintStatusTable(t *testing.T) {
+	var buf bytes.Buffer
+	printStatusTable(&buf, statusFixture(), false)
+
+	require.Equal(t, `VERSION  NAME    STATE    APPLIED AT           DURATION  APPLIED BY  TOOL VERSION
+1        init    applied  2025-08-01 12:00:00  2ms       ci@build    v1.4.0
+2        users   drifted  2025-08-01 12:00:00  0s        ci@build    v1.4.0
+3        gone    missing  2025-08-01 12:00:00  -         -           -
+4        orders  pending  -                    -         -           -
+
+1 applied, 1 pending, 1 drifted, 1 missing, current version 3
+`, buf.String())
+}
+
+func TestPrintStatusTable_Color(t *testing.T) {
+	var buf bytes.Buffer
+	printStatusTable(&buf, statusFixture(), true)
+
+	// colors wrap the padded state cell, so the columns still line up
+	header := `VERSION  NAME    STATE    APPLIED AT           DURATION  APPLIED BY  TOOL VERSION`
+	require.Equal(t, colorBold+header+colorReset+`
+1        init    `+colorGreen+`applied`+colorReset+`  2025-08-01 12:00:00  2ms       ci@build    v1.4.0
+2        users   `+colorPurple+`drifted`+colorReset+`  2025-08-01 12:00:00  0s        ci@build    v1.4.0
+3        gone    `+colorRed+`missing`+colorReset+`  2025-08-01 12:00:00  -         -           -
+4        orders  `+colorYellow+`pending`+colorReset+`  -                    -         -           -
+
+1 applied, 1 pending, `+colorPurple+`1 drifted`+colorReset+`, `+colorRed+`1 missing`+colorReset+`, current version 3
+`, buf.String())
+}
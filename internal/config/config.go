@@ -13,7 +13,11 @@ type Config struct {
 	} `mapstructure:"logger"`
 
 	Storage struct {
-		DSN string `mapstructure:"dsn"` // string «host=… port=…»
+		DSN     string `mapstructure:"dsn"`      // string «host=… port=…» or sqlite://file.db
+		Driver  string `mapstructure:"driver"`   // postgres|pgx|mysql|sqlite; empty means guess from DSN
+		Table   string `mapstructure:"table"`    // meta table; empty means gomigrator_schema_migrations
+		Schema  string `mapstructure:"schema"`   // schema of the meta table; empty means search_path
+		LockKey string `mapstructure:"lock_key"` // advisory lock key; empty means "gomigrator"
 	} `mapstructure:"storage"`
 }
 
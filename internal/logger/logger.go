@@ -33,7 +33,7 @@ type Logger struct {
 
 func New(lvl string) *Logger {
 	return &Logger{
-		l:     log.New(os.Stdout, "", log.LstdFlags),
+		l:     log.New(os.Stderr, "", log.LstdFlags),
 		level: parseLevel(lvl),
 	}
 }
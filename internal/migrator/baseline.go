@@ -0,0 +1,56 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hilltracer/gomigrator/internal/parser"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jmoiron/sqlx"
+)
+
+// Baseline adopts a database whose schema predates gomigrator: every file
+// up to and including version that is not applied yet is recorded as
+// applied without running its SQL. Returns the recorded steps.
+func (m *Migrator) Baseline(ctx context.Context, version int64) ([]Step, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := indexByVersion(all)[version]; !ok {
+		return nil, fmt.Errorf("migration file for version %d not found", version)
+	}
+
+	var steps []Step
+	err = m.store.WithLock(ctx, func(sess *sqlstorage.Session) error {
+		applied, err := sess.AppliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+		var migs []parser.Migration
+		for _, mig := range all {
+			if mig.Version <= version && !applied[mig.Version] {
+				migs = append(migs, mig)
+			}
+		}
+		err = m.inTx(ctx, sess, func(tx *sqlx.Tx) error {
+			for _, mig := range migs {
+				if err := m.store.MarkApplied(ctx, tx, mig.Version, mig.Name, mig.Checksum(), 0); err != nil {
+					return fmt.Errorf("baseline %s: %w", mig.Name, err)
+				}
+				if err := m.recordEvent(ctx, tx, mig, EventBaseline, DirectionUp, 0); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, mig := range migs {
+			steps = append(steps, newStep(mig, DirectionUp))
+		}
+		return nil
+	})
+	return steps, err
+}
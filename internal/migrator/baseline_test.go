@@ -0,0 +1,32 @@
+package migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseline_RecordsWithoutRunningSQL(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2, 3}, 1)
+
+	// no CREATE TABLE may run: only the meta rows are written
+	mock.ExpectExec(`INSERT INTO "gomigrator_schema_migrations"`).
+		WithArgs(markArgs(2, "t2")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	steps, err := m.Baseline(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, []Step{{Version: 2, Name: "t2", Direction: DirectionUp}}, steps)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBaseline_UnknownVersion(t *testing.T) {
+	m, _ := multiHelper(t, []int64{1, 2})
+
+	_, err := m.Baseline(context.Background(), 5)
+	require.ErrorContains(t, err, "version 5 not found")
+}
@@ -0,0 +1,53 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hilltracer/gomigrator/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_RunsGoMigrationInSameTx(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 3})
+	m.WithOptions(Options{GoMigrations: []parser.Migration{{
+		Version: 2,
+		Name:    "backfill",
+		UpFn: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE t1 SET id = id + 1")
+			return err
+		},
+	}}})
+
+	// one Begin (from multiHelper) and one Commit for all three
+	mock.ExpectExec(`CREATE TABLE t1\(id INT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(markArgs(1, "t1")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectExec(`UPDATE t1 SET id = id \+ 1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(markArgs(2, "backfill")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectExec(`CREATE TABLE t3\(id INT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(markArgs(3, "t3")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	steps, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, steps, 3)
+	require.Equal(t, "backfill", steps[1].Name)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrations_DuplicateGoVersion(t *testing.T) {
+	m, _ := multiHelper(t, []int64{1})
+	m.WithOptions(Options{GoMigrations: []parser.Migration{{Version: 1, Name: "dup"}}})
+
+	_, err := m.migrations()
+	require.ErrorContains(t, err, "duplicate migration version 1")
+}
@@ -0,0 +1,81 @@
+package migrator
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilltracer/gomigrator/internal/parser"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jmoiron/sqlx"
+)
+
+// EventKind names the command that produced a history event.
+type EventKind string
+
+const (
+	EventUp       EventKind = "up"
+	EventDown     EventKind = "down"
+	EventRedo     EventKind = "redo"
+	EventForce    EventKind = "force"
+	EventBaseline EventKind = "baseline"
+	EventRepair   EventKind = "repair"
+)
+
+// HistoryEntry is one event of the append-only migration history.
+type HistoryEntry struct {
+	ID          int64
+	Version     int64
+	Name        string
+	Kind        EventKind
+	Direction   Direction
+	Checksum    string        // of the file at the time; empty if there was none
+	Duration    time.Duration // time spent running the migration's SQL
+	OSUser      string
+	Hostname    string
+	ToolVersion string
+	At          time.Time
+}
+
+// Returns every recorded event, oldest first. Unlike Status it keeps
+// migrations that were rolled back or forced since.
+func (m *Migrator) History(ctx context.Context) ([]HistoryEntry, error) {
+	events, err := m.store.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]HistoryEntry, len(events))
+	for i, ev := range events {
+		res[i] = HistoryEntry{
+			ID:          ev.ID,
+			Version:     ev.Version,
+			Name:        ev.Name,
+			Kind:        EventKind(ev.Kind),
+			Direction:   Direction(ev.Direction),
+			Checksum:    ev.Checksum,
+			Duration:    time.Duration(ev.DurationMs) * time.Millisecond,
+			OSUser:      ev.OSUser,
+			Hostname:    ev.Hostname,
+			ToolVersion: ev.ToolVersion,
+			At:          ev.CreatedAt,
+		}
+	}
+	return res, nil
+}
+
+// Appends an event about mig to the history inside tx.
+func (m *Migrator) recordEvent(ctx context.Context, tx *sqlx.Tx, mig parser.Migration,
+	kind EventKind, dir Direction, took time.Duration,
+) error {
+	var sum string
+	if mig.Path != "" || mig.UpFn != nil || mig.DownFn != nil { // not a bare version
+		sum = mig.Checksum()
+	}
+	return m.store.AppendHistory(ctx, tx, sqlstorage.HistoryEvent{
+		Version:    mig.Version,
+		Name:       mig.Name,
+		Kind:       string(kind),
+		Direction:  string(dir),
+		Checksum:   sum,
+		DurationMs: took.Milliseconds(),
+	})
+}
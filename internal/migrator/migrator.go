@@ -2,9 +2,13 @@ package migrator
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/hilltracer/gomigrator/internal/parser"
 	"github.com/hilltracer/gomigrator/internal/sqlstorage"
@@ -15,11 +19,172 @@ import (
 type Migrator struct {
 	store *sqlstorage.Store
 	dir   string
+	opts  Options
 }
 
+// Options tune how migrations are applied. The zero value keeps the defaults.
+type Options struct {
+	// FailOnDrift makes up-style commands refuse to run while an applied
+	// migration file differs from the checksum recorded in the DB.
+	FailOnDrift bool
+	// TxMode selects how migrations are grouped into transactions.
+	TxMode TxMode
+	// GoMigrations are merged with the files by version; they must have
+	// UpFn/DownFn set instead of SQL.
+	GoMigrations []parser.Migration
+	// FS, when set, is where migration files are read from; the Migrator's
+	// dir is then a path inside it ("" or "." for its root).
+	FS fs.FS
+	// DryRun executes everything in one transaction and rolls it back
+	// instead of committing. NoTransaction migrations cannot be dry-run,
+	// and neither can anything on a database without transactional DDL.
+	DryRun bool
+	// AllowMissing lets up-style commands apply pending migrations older
+	// than the newest applied one (in version order, with a warning)
+	// instead of failing with ErrMissingMigrations.
+	AllowMissing bool
+	// AllowRemoveAll lets Repair remove every meta row when none of them
+	// has a migration file, instead of failing with ErrRepairRemovesAll.
+	AllowRemoveAll bool
+	// Warn, if set, receives warnings about the run, e.g. that the
+	// database commits DDL implicitly.
+	Warn func(msg string)
+}
+
+// errDryRun aborts the transaction of a dry run after all work succeeded.
+var errDryRun = errors.New("dry run")
+
+// TxMode is the transaction strategy used when several migrations run at once.
+type TxMode string
+
+const (
+	TxModeSingle       TxMode = "single"        // one transaction for the whole run (default)
+	TxModePerMigration TxMode = "per-migration" // commit after each file and its meta row
+)
+
+// Reports whether mode is a known TxMode; empty means TxModeSingle.
+func (mode TxMode) Valid() bool {
+	switch mode {
+	case "", TxModeSingle, TxModePerMigration:
+		return true
+	}
+	return false
+}
+
+// PartialError is returned when a command fails after some migrations
+// were already committed; Committed lists them in execution order.
+type PartialError struct {
+	Committed []Step
+	Err       error
+}
+
+func (e *PartialError) Error() string {
+	names := make([]string, len(e.Committed))
+	for i, s := range e.Committed {
+		names[i] = fmt.Sprintf("%s %d_%s", s.Direction, s.Version, s.Name)
+	}
+	return fmt.Sprintf("%v (committed before failure: %s)", e.Err, strings.Join(names, ", "))
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// State of a migration as seen by Status.
+type State string
+
+const (
+	StateApplied     State = "applied"      // file exists and is recorded as applied
+	StatePending     State = "pending"      // file exists but was never applied
+	StateMissingFile State = "missing-file" // recorded in the DB but the file is gone
+	StateDrifted     State = "drifted"      // applied, but the file changed since (see Validate)
+)
+
 type StatusEntry struct {
 	Version   int64
+	Name      string
+	Path      string // empty for StateMissingFile
+	State     State
 	IsApplied bool
+	AppliedAt time.Time // zero unless recorded in the DB
+
+	// What the DB recorded about the run. HasRunInfo is false for rows
+	// written by older releases, which recorded none of it; versions marked
+	// by force or baseline record a zero Duration.
+	HasRunInfo  bool
+	Duration    time.Duration
+	AppliedBy   string // user@host
+	ToolVersion string
+}
+
+// Copies what the DB recorded about a migration into e.
+func (e *StatusEntry) setRecord(r sqlstorage.Record) {
+	e.IsApplied = r.IsApplied
+	e.AppliedAt = r.AppliedAt
+	// the columns are NOT NULL, so a run faster than 1ms and a row from
+	// before they existed both read 0 ms; only the latter has no author
+	e.HasRunInfo = r.OSUser != "" || r.Hostname != "" || r.ToolVersion != ""
+	e.Duration = time.Duration(r.DurationMs) * time.Millisecond
+	e.ToolVersion = r.ToolVersion
+	e.AppliedBy = r.OSUser
+	if r.OSUser != "" && r.Hostname != "" {
+		e.AppliedBy += "@" + r.Hostname
+	}
+}
+
+// Returns the migration files merged with the registered Go migrations,
+// sorted by version.
+func (m *Migrator) migrations() ([]parser.Migration, error) {
+	var (
+		all []parser.Migration
+		err error
+	)
+	syntax := parser.SyntaxPostgres
+	if m.store.Dialect().Name() == sqlstorage.MySQL.Name() {
+		syntax = parser.SyntaxMySQL
+	}
+	if m.opts.FS != nil {
+		dir := m.dir
+		if dir == "" {
+			dir = "."
+		}
+		all, err = parser.ParseFSSyntax(m.opts.FS, dir, syntax)
+	} else {
+		all, err = parser.ParseDirSyntax(m.dir, syntax)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(m.opts.GoMigrations) == 0 {
+		return all, nil
+	}
+	byVersion := indexByVersion(all)
+	for _, mig := range m.opts.GoMigrations {
+		if other, ok := byVersion[mig.Version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", mig.Version, other.Name, mig.Name)
+		}
+		byVersion[mig.Version] = mig
+		all = append(all, mig)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// Direction in which a migration was executed.
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// Step is one migration executed by a command.
+type Step struct {
+	Version   int64
+	Name      string
+	Direction Direction
+}
+
+func newStep(mig parser.Migration, dir Direction) Step {
+	return Step{Version: mig.Version, Name: mig.Name, Direction: dir}
 }
 
 // Creates a Migrator from an already-opened Store (keeps old tests intact).
@@ -28,14 +193,20 @@ func New(store *sqlstorage.Store, dir string) *Migrator {
 }
 
 // Open connection to the database and return a Migrator instance.
-func NewFromDSN(ctx context.Context, dsn, dir string) (*Migrator, error) {
-	store, err := sqlstorage.Connect(ctx, dsn)
+func NewFromDSN(ctx context.Context, dsn, dir string, storeOpts sqlstorage.Options) (*Migrator, error) {
+	store, err := sqlstorage.Connect(ctx, dsn, storeOpts)
 	if err != nil {
 		return nil, err
 	}
 	return &Migrator{store: store, dir: dir}, nil
 }
 
+// Sets options and returns the same Migrator for chaining.
+func (m *Migrator) WithOptions(opts Options) *Migrator {
+	m.opts = opts
+	return m
+}
+
 func (m *Migrator) Close() error { return m.store.Close() }
 
 func isExecutableSQL(sql string) bool {
@@ -51,40 +222,406 @@ func isExecutableSQL(sql string) bool {
 	return false
 }
 
-// Applies every {is_applied = false} migration.
-func (m *Migrator) Up(ctx context.Context) error {
-	all, err := parser.ParseDir(m.dir)
+// Applies every {is_applied = false} migration and returns the applied steps.
+func (m *Migrator) Up(ctx context.Context) ([]Step, error) {
+	all, err := m.migrations()
 	if err != nil {
+		return nil, err
+	}
+	return m.upTo(ctx, all, math.MaxInt64, 0)
+}
+
+// Applies pending migrations up to and including version.
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]Step, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := indexByVersion(all)[version]; !ok {
+		return nil, fmt.Errorf("migration file for version %d not found", version)
+	}
+	return m.upTo(ctx, all, version, 0)
+}
+
+// Applies only the oldest pending migration.
+// Returns no steps if everything is already applied.
+func (m *Migrator) UpByOne(ctx context.Context) ([]Step, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	return m.upTo(ctx, all, math.MaxInt64, 1)
+}
+
+// Applies pending migrations with version <= target, at most limit of them
+// (0 means no limit), and returns the applied steps.
+func (m *Migrator) upTo(ctx context.Context, all []parser.Migration, target int64, limit int) ([]Step, error) {
+	var steps []Step
+	err := m.store.WithLock(ctx, func(sess *sqlstorage.Session) error {
+		if m.opts.FailOnDrift {
+			if err := m.checkDrift(ctx, sess, all); err != nil {
+				return err
+			}
+		}
+		applied, err := sess.AppliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+		pending := selectUp(all, applied, target, limit)
+		if err := m.checkMissing(pending, applied); err != nil {
+			return err
+		}
+		steps, err = m.run(ctx, sess, pending, DirectionUp)
 		return err
+	})
+	return steps, partial(steps, err)
+}
+
+// Returns pending migrations with version <= target, oldest first,
+// at most limit of them (0 means no limit).
+func selectUp(all []parser.Migration, applied map[int64]bool, target int64, limit int) []parser.Migration {
+	var pending []parser.Migration
+	for _, mig := range all {
+		if mig.Version > target || (limit > 0 && len(pending) == limit) {
+			break
+		}
+		if applied[mig.Version] { // already done
+			continue
+		}
+		pending = append(pending, mig)
+	}
+	return pending
+}
+
+// Rolls back every applied migration newer than version, newest first.
+// Version 0 rolls back everything.
+func (m *Migrator) DownTo(ctx context.Context, version int64) ([]Step, error) {
+	if version < 0 {
+		return nil, fmt.Errorf("invalid target version %d", version)
 	}
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	return m.downTo(ctx, all, version, 0)
+}
+
+// Rolls back the n latest applied migrations, newest first.
+func (m *Migrator) DownN(ctx context.Context, n int) ([]Step, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of migrations %d", n)
+	}
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	return m.downTo(ctx, all, 0, n)
+}
+
+// Rolls back every applied migration, newest first.
+func (m *Migrator) Reset(ctx context.Context) ([]Step, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	return m.downTo(ctx, all, 0, 0)
+}
 
-	return m.store.WithExclusive(ctx, func(tx *sqlx.Tx) error {
-		applied, err := m.store.AppliedVersions(ctx)
+// Rolls back applied migrations with version > target, newest first,
+// at most limit of them (0 means no limit), and returns the rolled back steps.
+func (m *Migrator) downTo(ctx context.Context, all []parser.Migration, target int64, limit int) ([]Step, error) {
+	var steps []Step
+	err := m.store.WithLock(ctx, func(sess *sqlstorage.Session) error {
+		applied, err := sess.AppliedVersions(ctx)
 		if err != nil {
 			return err
 		}
-		for _, mig := range all {
-			if applied[mig.Version] { // already done
-				continue
-			}
-			if !isExecutableSQL(mig.UpSQL) {
-				return fmt.Errorf("%s has empty Up block", mig.Name)
+		todo, err := selectDown(all, applied, target, limit)
+		if err != nil {
+			return err
+		}
+		steps, err = m.run(ctx, sess, todo, DirectionDown)
+		return err
+	})
+	return steps, partial(steps, err)
+}
+
+// Returns applied migrations with version > target, newest first,
+// at most limit of them (0 means no limit).
+func selectDown(all []parser.Migration, applied map[int64]bool, target int64, limit int) ([]parser.Migration, error) {
+	byVersion := indexByVersion(all)
+
+	var todo []parser.Migration
+	for _, v := range appliedDesc(applied) {
+		if v <= target || (limit > 0 && len(todo) == limit) {
+			break
+		}
+		mig, ok := byVersion[v]
+		if !ok {
+			return nil, fmt.Errorf("migration file for version %d not found", v)
+		}
+		todo = append(todo, mig)
+	}
+	return todo, nil
+}
+
+// Executes migs in order; must be called under the lock.
+// In TxModeSingle consecutive transactional migrations share one transaction,
+// in TxModePerMigration each gets its own. Every NoTransaction migration
+// commits what came before it and runs on its own.
+// On failure the steps committed so far are returned along with the error.
+func (m *Migrator) run(ctx context.Context, sess *sqlstorage.Session, migs []parser.Migration,
+	dir Direction,
+) ([]Step, error) {
+	if m.opts.DryRun {
+		return m.dryRun(ctx, sess, migs, dir)
+	}
+	if len(migs) > 0 {
+		m.warnDDLAutoCommit()
+	}
+	var steps []Step
+	for i := 0; i < len(migs); {
+		if migs[i].NoTransaction {
+			if err := m.applyNoTx(ctx, sess, migs[i], dir); err != nil {
+				return steps, err
 			}
-			if _, err := tx.ExecContext(ctx, mig.UpSQL); err != nil {
-				return fmt.Errorf("up %s: %w", mig.Name, err)
+			steps = append(steps, newStep(migs[i], dir))
+			i++
+			continue
+		}
+
+		j := i + 1
+		for m.opts.TxMode != TxModePerMigration && j < len(migs) && !migs[j].NoTransaction {
+			j++
+		}
+		batch := migs[i:j]
+		err := m.inTx(ctx, sess, func(tx *sqlx.Tx) error {
+			for _, mig := range batch {
+				if err := m.apply(ctx, tx, mig, dir); err != nil {
+					return err
+				}
 			}
-			if err := m.store.MarkApplied(ctx, tx, mig.Version, mig.Name); err != nil {
+			return nil
+		})
+		if err != nil {
+			return steps, err
+		}
+		for _, mig := range batch {
+			steps = append(steps, newStep(mig, dir))
+		}
+		i = j
+	}
+	return steps, nil
+}
+
+// Executes migs in a single transaction that is rolled back at the end,
+// whatever the TxMode, so later migrations see the effects of earlier ones.
+func (m *Migrator) dryRun(ctx context.Context, sess *sqlstorage.Session, migs []parser.Migration,
+	dir Direction,
+) ([]Step, error) {
+	if err := m.checkDryRun(migs...); err != nil {
+		return nil, err
+	}
+	err := m.inTx(ctx, sess, func(tx *sqlx.Tx) error {
+		for _, mig := range migs {
+			if err := m.apply(ctx, tx, mig, dir); err != nil {
 				return err
 			}
 		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]Step, len(migs))
+	for i, mig := range migs {
+		steps[i] = newStep(mig, dir)
+	}
+	return steps, nil
+}
+
+// Warns that transactions will not make migrations atomic
+// on databases that commit DDL implicitly.
+func (m *Migrator) warnDDLAutoCommit() {
+	if d := m.store.Dialect(); !d.TransactionalDDL() && m.opts.Warn != nil {
+		m.opts.Warn(d.Name() + " commits DDL implicitly: a failing migration may leave" +
+			" earlier statements applied even inside a transaction")
+	}
+}
+
+// Reports why migs cannot be dry-run, if they cannot.
+func (m *Migrator) checkDryRun(migs ...parser.Migration) error {
+	if d := m.store.Dialect(); !d.TransactionalDDL() {
+		return fmt.Errorf("%s commits DDL implicitly, so a dry run cannot be rolled back", d.Name())
+	}
+	for _, mig := range migs {
+		if mig.NoTransaction {
+			return fmt.Errorf("%s is a NoTransaction migration and cannot be dry-run", mig.Name)
+		}
+	}
+	return nil
+}
+
+// Runs fn in a transaction; on a dry run the transaction is rolled back
+// even if fn succeeds.
+func (m *Migrator) inTx(ctx context.Context, sess *sqlstorage.Session, fn func(*sqlx.Tx) error) error {
+	if !m.opts.DryRun {
+		return sess.InTx(ctx, fn)
+	}
+	err := sess.InTx(ctx, func(tx *sqlx.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// Wraps err into a PartialError when some steps were already committed.
+func partial(committed []Step, err error) error {
+	if err == nil || len(committed) == 0 {
+		return err
+	}
+	return &PartialError{Committed: committed, Err: err}
+}
+
+func (m *Migrator) apply(ctx context.Context, tx *sqlx.Tx, mig parser.Migration, dir Direction) error {
+	if dir == DirectionDown {
+		return m.applyDown(ctx, tx, mig)
+	}
+	return m.applyUp(ctx, tx, mig)
+}
+
+// Executes a NoTransaction migration directly on the DB and updates
+// the meta table in a separate transaction only after it succeeded.
+func (m *Migrator) applyNoTx(ctx context.Context, sess *sqlstorage.Session, mig parser.Migration, dir Direction) error {
+	start := time.Now()
+	if dir == DirectionDown {
+		if !isExecutableSQL(mig.DownSQL) {
+			return fmt.Errorf("%s has empty Down block (cannot rollback)", mig.Name)
+		}
+		if err := execStatements(ctx, sess.Exec, mig.DownStatements); err != nil {
+			return fmt.Errorf("down %s: %w", mig.Name, err)
+		}
+		took := time.Since(start)
+		return m.inTx(ctx, sess, func(tx *sqlx.Tx) error {
+			if err := m.store.MarkRolledBack(ctx, tx, mig.Version); err != nil {
+				return err
+			}
+			return m.recordEvent(ctx, tx, mig, EventDown, DirectionDown, took)
+		})
+	}
+	if !isExecutableSQL(mig.UpSQL) {
+		return fmt.Errorf("%s has empty Up block", mig.Name)
+	}
+	if err := execStatements(ctx, sess.Exec, mig.UpStatements); err != nil {
+		return fmt.Errorf("up %s: %w", mig.Name, err)
+	}
+	took := time.Since(start)
+	return m.inTx(ctx, sess, func(tx *sqlx.Tx) error {
+		if err := m.store.MarkApplied(ctx, tx, mig.Version, mig.Name, mig.Checksum(), took); err != nil {
+			return err
+		}
+		return m.recordEvent(ctx, tx, mig, EventUp, DirectionUp, took)
+	})
+}
+
+// Executes the Up block of mig and records it inside tx.
+func (m *Migrator) applyUp(ctx context.Context, tx *sqlx.Tx, mig parser.Migration) error {
+	if !hasUp(mig) {
+		return fmt.Errorf("%s has empty Up block", mig.Name)
+	}
+	start := time.Now()
+	if err := execUp(ctx, tx, mig); err != nil {
+		return fmt.Errorf("up %s: %w", mig.Name, err)
+	}
+	took := time.Since(start)
+	if err := m.store.MarkApplied(ctx, tx, mig.Version, mig.Name, mig.Checksum(), took); err != nil {
+		return err
+	}
+	return m.recordEvent(ctx, tx, mig, EventUp, DirectionUp, took)
+}
+
+// Executes the Down block of mig and removes its record inside tx.
+func (m *Migrator) applyDown(ctx context.Context, tx *sqlx.Tx, mig parser.Migration) error {
+	if !hasDown(mig) {
+		return fmt.Errorf("%s has empty Down block (cannot rollback)", mig.Name)
+	}
+	start := time.Now()
+	if err := execDown(ctx, tx, mig); err != nil {
+		return fmt.Errorf("down %s: %w", mig.Name, err)
+	}
+	took := time.Since(start)
+	if err := m.store.MarkRolledBack(ctx, tx, mig.Version); err != nil {
+		return err
+	}
+	return m.recordEvent(ctx, tx, mig, EventDown, DirectionDown, took)
+}
+
+// Runs the Go function or the SQL statements of the Up block inside tx.
+func execUp(ctx context.Context, tx *sqlx.Tx, mig parser.Migration) error {
+	if mig.UpFn != nil {
+		return mig.UpFn(ctx, tx.Tx)
+	}
+	return execStatements(ctx, txExec(tx), mig.UpStatements)
+}
+
+// Runs the Go function or the SQL statements of the Down block inside tx.
+func execDown(ctx context.Context, tx *sqlx.Tx, mig parser.Migration) error {
+	if mig.DownFn != nil {
+		return mig.DownFn(ctx, tx.Tx)
+	}
+	return execStatements(ctx, txExec(tx), mig.DownStatements)
+}
+
+func hasUp(mig parser.Migration) bool { return mig.UpFn != nil || isExecutableSQL(mig.UpSQL) }
+
+func hasDown(mig parser.Migration) bool { return mig.DownFn != nil || isExecutableSQL(mig.DownSQL) }
+
+// Runs stmts one by one through exec; the error names the failing statement.
+func execStatements(ctx context.Context, exec func(context.Context, string) error, stmts []string) error {
+	for i, stmt := range stmts {
+		if err := exec(ctx, stmt); err != nil {
+			return fmt.Errorf("statement %d of %d: %w", i+1, len(stmts), err)
+		}
+	}
+	return nil
+}
+
+func txExec(tx *sqlx.Tx) func(context.Context, string) error {
+	return func(ctx context.Context, query string) error {
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	}
+}
+
+func indexByVersion(all []parser.Migration) map[int64]parser.Migration {
+	res := make(map[int64]parser.Migration, len(all))
+	for _, mig := range all {
+		res[mig.Version] = mig
+	}
+	return res
+}
+
+// Returns applied versions sorted from newest to oldest.
+func appliedDesc(applied map[int64]bool) []int64 {
+	res := make([]int64, 0, len(applied))
+	for v, ok := range applied {
+		if ok {
+			res = append(res, v)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] > res[j] })
+	return res
 }
 
 // Returns the highest-applied migration file.
 // If no migration was applied yet, it returns (nil, nil).
-func (m *Migrator) lastAppliedMigration(ctx context.Context) (*parser.Migration, error) {
-	applied, err := m.store.AppliedVersions(ctx)
+func (m *Migrator) lastAppliedMigration(ctx context.Context, sess *sqlstorage.Session) (*parser.Migration, error) {
+	applied, err := sess.AppliedVersions(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +634,7 @@ func (m *Migrator) lastAppliedMigration(ctx context.Context) (*parser.Migration,
 	if last == 0 {
 		return nil, nil
 	}
-	all, err := parser.ParseDir(m.dir)
+	all, err := m.migrations()
 	if err != nil {
 		return nil, err
 	}
@@ -110,61 +647,125 @@ func (m *Migrator) lastAppliedMigration(ctx context.Context) (*parser.Migration,
 }
 
 // Rolls back the latest applied migration.
-func (m *Migrator) Down(ctx context.Context) error {
-	return m.store.WithExclusive(ctx, func(tx *sqlx.Tx) error {
-		mig, err := m.lastAppliedMigration(ctx)
-		if err != nil {
-			return err
-		}
-		if mig == nil {
-			return nil // nothing applied yet
-		}
-		if !isExecutableSQL(mig.DownSQL) {
-			return fmt.Errorf("%s has empty Down block (cannot rollback)", mig.Name)
-		}
-
-		if _, err := tx.ExecContext(ctx, mig.DownSQL); err != nil {
-			return fmt.Errorf("down %s: %w", mig.Name, err)
-		}
-		return m.store.MarkRolledBack(ctx, tx, mig.Version)
-	})
+// Returns no steps if nothing is applied.
+func (m *Migrator) Down(ctx context.Context) ([]Step, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	return m.downTo(ctx, all, 0, 1)
 }
 
-// Redo = Down + Up of the last migration, in a single transaction.
-func (m *Migrator) Redo(ctx context.Context) error {
-	return m.store.WithExclusive(ctx, func(tx *sqlx.Tx) error {
-		mig, err := m.lastAppliedMigration(ctx)
+// Redo = Down + Up of the last migration, in a single transaction
+// (or without one for NoTransaction migrations). Returns the down and up
+// steps, or none if nothing is applied.
+func (m *Migrator) Redo(ctx context.Context) ([]Step, error) {
+	var steps []Step
+	err := m.store.WithLock(ctx, func(sess *sqlstorage.Session) error {
+		mig, err := m.lastAppliedMigration(ctx, sess)
 		if err != nil {
 			return err
 		}
 		if mig == nil {
 			return nil // nothing to redo
 		}
-		if !isExecutableSQL(mig.UpSQL) || !isExecutableSQL(mig.DownSQL) {
+		if !hasUp(*mig) || !hasDown(*mig) {
 			return fmt.Errorf("%s must have both Up and Down blocks for redo", mig.Name)
 		}
-		if _, err := tx.ExecContext(ctx, mig.DownSQL); err != nil {
-			return fmt.Errorf("redo-down %s: %w", mig.Name, err)
+		if m.opts.DryRun {
+			if err := m.checkDryRun(*mig); err != nil {
+				return err
+			}
+		} else {
+			m.warnDDLAutoCommit()
 		}
-		if _, err := tx.ExecContext(ctx, mig.UpSQL); err != nil {
-			return fmt.Errorf("redo-up %s: %w", mig.Name, err)
+		start := time.Now()
+		if mig.NoTransaction {
+			if err := execStatements(ctx, sess.Exec, mig.DownStatements); err != nil {
+				return fmt.Errorf("redo-down %s: %w", mig.Name, err)
+			}
+			if err := execStatements(ctx, sess.Exec, mig.UpStatements); err != nil {
+				return fmt.Errorf("redo-up %s: %w", mig.Name, err)
+			}
+			took := time.Since(start)
+			err := m.inTx(ctx, sess, func(tx *sqlx.Tx) error {
+				if err := m.store.MarkApplied(ctx, tx, mig.Version, mig.Name, mig.Checksum(), took); err != nil {
+					return err
+				}
+				return m.recordEvent(ctx, tx, *mig, EventRedo, DirectionUp, took)
+			})
+			if err == nil {
+				steps = []Step{newStep(*mig, DirectionDown), newStep(*mig, DirectionUp)}
+			}
+			return err
 		}
-		return m.store.MarkApplied(ctx, tx, mig.Version, mig.Name)
+		err = m.inTx(ctx, sess, func(tx *sqlx.Tx) error {
+			if err := execDown(ctx, tx, *mig); err != nil {
+				return fmt.Errorf("redo-down %s: %w", mig.Name, err)
+			}
+			if err := execUp(ctx, tx, *mig); err != nil {
+				return fmt.Errorf("redo-up %s: %w", mig.Name, err)
+			}
+			took := time.Since(start)
+			if err := m.store.MarkApplied(ctx, tx, mig.Version, mig.Name, mig.Checksum(), took); err != nil {
+				return err
+			}
+			return m.recordEvent(ctx, tx, *mig, EventRedo, DirectionUp, took)
+		})
+		if err == nil {
+			steps = []Step{newStep(*mig, DirectionDown), newStep(*mig, DirectionUp)}
+		}
+		return err
 	})
+	return steps, err
 }
 
-// Returns sorted migration statuses.
+// Returns sorted migration statuses: every file in the migrations dir
+// merged with every row of the meta table.
 func (m *Migrator) Status(ctx context.Context) ([]StatusEntry, error) {
-	applied, err := m.store.AppliedVersions(ctx)
+	all, err := m.migrations()
 	if err != nil {
 		return nil, err
 	}
-	entries := make([]StatusEntry, 0, len(applied))
-	for v, ok := range applied {
-		entries = append(entries, StatusEntry{
-			Version:   v,
-			IsApplied: ok,
-		})
+	records, err := m.store.Records(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]sqlstorage.Record, len(records))
+	for _, r := range records {
+		byVersion[r.Version] = r
+	}
+
+	entries := make([]StatusEntry, 0, len(all)+len(records))
+	for _, mig := range all {
+		e := StatusEntry{
+			Version: mig.Version,
+			Name:    mig.Name,
+			Path:    mig.Path,
+			State:   StatePending,
+		}
+		if r, ok := byVersion[mig.Version]; ok {
+			delete(byVersion, mig.Version)
+			e.setRecord(r)
+			switch {
+			case !r.IsApplied:
+			case r.Checksum != "" && r.Checksum != mig.Checksum():
+				e.State = StateDrifted
+			default:
+				e.State = StateApplied
+			}
+		}
+		entries = append(entries, e)
+	}
+	for _, r := range byVersion { // rows left without a file
+		e := StatusEntry{
+			Version: r.Version,
+			Name:    r.Name,
+			State:   StateMissingFile,
+		}
+		e.setRecord(r)
+		entries = append(entries, e)
 	}
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].Version < entries[j].Version
@@ -2,6 +2,7 @@ package migrator
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -44,7 +45,7 @@ func helper(t *testing.T, upSQL, downSQL string, applied bool) (*Migrator, sqlmo
 	if applied {
 		rows.AddRow(version, true)
 	}
-	mock.ExpectQuery("SELECT version, is_applied FROM gomigrator_schema_migrations").
+	mock.ExpectQuery("SELECT version, is_applied FROM \"gomigrator_schema_migrations\"").
 		WillReturnRows(rows)
 
 	return m, mock, func() {
@@ -68,14 +69,19 @@ func TestUp_AppliesMissingMigrations(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectExec(`CREATE TABLE qwe\(id INT\);`).
 		WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec("INSERT INTO gomigrator_schema_migrations").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
 	mock.ExpectCommit()
 	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	require.NoError(t, m.Up(context.Background()))
+	steps, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+	require.Equal(t, DirectionUp, steps[0].Direction)
 }
 
 func TestDown_RollsBackLast(t *testing.T) {
@@ -89,13 +95,17 @@ func TestDown_RollsBackLast(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectExec(`DROP TABLE qwe;`).
 		WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec("DELETE FROM gomigrator_schema_migrations").
+	mock.ExpectExec("DELETE FROM \"gomigrator_schema_migrations\"").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
 	mock.ExpectCommit()
 	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	require.NoError(t, m.Down(context.Background()))
+	steps, err := m.Down(context.Background())
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+	require.Equal(t, DirectionDown, steps[0].Direction)
 }
 
 func TestRedo_DownThenUp(t *testing.T) {
@@ -111,11 +121,36 @@ func TestRedo_DownThenUp(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectExec(`CREATE TABLE qwe\(id INT\);`).
 		WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec("INSERT INTO gomigrator_schema_migrations").
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
 	mock.ExpectCommit()
 	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	require.NoError(t, m.Redo(context.Background()))
+	steps, err := m.Redo(context.Background())
+	require.NoError(t, err)
+	require.Len(t, steps, 2)
+	require.Equal(t, DirectionDown, steps[0].Direction)
+	require.Equal(t, DirectionUp, steps[1].Direction)
+}
+
+func TestUp_ReportsFailingStatement(t *testing.T) {
+	upSQL := "CREATE TABLE qwe(id INT);\nINSERT INTO qwe VALUES ('x');"
+	m, mock, done := helper(t, upSQL, "DROP TABLE qwe;", false)
+	defer done()
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`^CREATE TABLE qwe\(id INT\);$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`^INSERT INTO qwe`).
+		WillReturnError(errors.New("invalid input syntax"))
+	mock.ExpectRollback()
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	_, err := m.Up(context.Background())
+	require.ErrorContains(t, err, "statement 2 of 2")
 }
@@ -0,0 +1,53 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hilltracer/gomigrator/internal/parser"
+)
+
+// ErrMissingMigrations is returned by up-style commands when a pending
+// migration is older than the newest applied one (typically after merging
+// branches) and AllowMissing is not set.
+var ErrMissingMigrations = errors.New("missing migrations older than the current version")
+
+// Returns the migrations of pending whose version is below the newest
+// applied one, i.e. those that would be applied out of order.
+func findMissing(pending []parser.Migration, applied map[int64]bool) []parser.Migration {
+	var current int64
+	for v, ok := range applied {
+		if ok && v > current {
+			current = v
+		}
+	}
+	var res []parser.Migration
+	for _, mig := range pending {
+		if mig.Version < current {
+			res = append(res, mig)
+		}
+	}
+	return res
+}
+
+// Fails with ErrMissingMigrations if pending would apply anything out of
+// order; with AllowMissing it only warns.
+func (m *Migrator) checkMissing(pending []parser.Migration, applied map[int64]bool) error {
+	missing := findMissing(pending, applied)
+	if len(missing) == 0 {
+		return nil
+	}
+	names := make([]string, len(missing))
+	for i, mig := range missing {
+		names[i] = fmt.Sprintf("%d_%s", mig.Version, mig.Name)
+	}
+	list := strings.Join(names, ", ")
+	if !m.opts.AllowMissing {
+		return fmt.Errorf("%w: %s (apply them with allow-missing)", ErrMissingMigrations, list)
+	}
+	if m.opts.Warn != nil {
+		m.opts.Warn("applying migrations out of order: " + list)
+	}
+	return nil
+}
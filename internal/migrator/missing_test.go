@@ -0,0 +1,45 @@
+package migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_FailsOnMissingMigrations(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2, 3, 4}, 1, 3)
+	// multiHelper expects a Begin that never comes
+	mock.MatchExpectationsInOrder(false)
+	expectUnlock(mock)
+
+	_, err := m.Up(context.Background())
+	require.ErrorIs(t, err, ErrMissingMigrations)
+	require.Contains(t, err.Error(), "2_t2")
+	require.NotContains(t, err.Error(), "4_t4")
+}
+
+func TestUp_AllowMissingAppliesInVersionOrder(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2, 3, 4}, 1, 3)
+	var warnings []string
+	m.WithOptions(Options{AllowMissing: true, Warn: func(msg string) { warnings = append(warnings, msg) }})
+
+	for _, v := range []string{"2", "4"} {
+		mock.ExpectExec(`CREATE TABLE t` + v).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO "gomigrator_schema_migrations"`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectHistory(mock)
+	}
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	steps, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Step{
+		{Version: 2, Name: "t2", Direction: DirectionUp},
+		{Version: 4, Name: "t4", Direction: DirectionUp},
+	}, steps)
+	require.Equal(t, []string{"applying migrations out of order: 2_t2"}, warnings)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,70 @@
+package migrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func mysqlHelper(t *testing.T, versions []int64) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	store := sqlstorage.NewWithDialect(sqlx.NewDb(db, "mysql"), sqlstorage.MySQL, 42)
+	return New(store, writeMigrations(t, versions)), mock
+}
+
+func TestUp_MySQLWarnsAboutDDLAutoCommit(t *testing.T) {
+	m, mock := mysqlHelper(t, []int64{1})
+	var warnings []string
+	m.WithOptions(Options{Warn: func(msg string) { warnings = append(warnings, msg) }})
+
+	mock.ExpectQuery(`SELECT GET_LOCK`).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	mock.ExpectQuery("SELECT version, is_applied FROM `gomigrator_schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "is_applied"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE t1\(id INT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `gomigrator_schema_migrations`").
+		WithArgs(markArgs(1, "t1")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO `gomigrator_history`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT RELEASE_LOCK`).WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(1))
+
+	_, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	require.Contains(t, warnings[0], "mysql commits DDL implicitly")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_MySQLRefusesDryRun(t *testing.T) {
+	m, mock := mysqlHelper(t, []int64{1})
+	m.WithOptions(Options{DryRun: true})
+
+	mock.ExpectQuery(`SELECT GET_LOCK`).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	mock.ExpectQuery("SELECT version, is_applied FROM `gomigrator_schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "is_applied"}))
+	mock.ExpectQuery(`SELECT RELEASE_LOCK`).WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(1))
+
+	_, err := m.Up(context.Background())
+	require.ErrorContains(t, err, "dry run cannot be rolled back")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrations_MySQLSplitsWithMySQLRules(t *testing.T) {
+	m, _ := mysqlHelper(t, nil)
+	body := "-- +gomigrator Up\n# don't\nINSERT INTO t VALUES ('it\\'s; fine');\nSELECT 1;\n"
+	require.NoError(t, os.WriteFile(filepath.Join(m.dir, "1_esc.sql"), []byte(body), 0o644))
+
+	all, err := m.migrations()
+	require.NoError(t, err)
+	require.Equal(t, []string{"# don't\nINSERT INTO t VALUES ('it\\'s; fine');", "SELECT 1;"},
+		all[0].UpStatements)
+}
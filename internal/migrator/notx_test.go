@@ -0,0 +1,70 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// noTxHelper writes a transactional migration 1 and a NoTransaction migration 2.
+func noTxHelper(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+
+	dir := t.TempDir()
+	first := "-- +gomigrator Up\nCREATE TABLE qwe(id INT);\n-- +gomigrator Down\nDROP TABLE qwe;\n"
+	second := "-- +gomigrator NoTransaction\n-- +gomigrator Up\nCREATE INDEX CONCURRENTLY qwe_id ON qwe(id);\n" +
+		"-- +gomigrator Down\nDROP INDEX CONCURRENTLY qwe_id;\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1_table.sql"), []byte(first), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2_index.sql"), []byte(second), 0o644))
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT version, is_applied FROM \"gomigrator_schema_migrations\"").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "is_applied"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE qwe\(id INT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(markArgs(1, "table")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+
+	return New(sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42), dir), mock
+}
+
+func TestUp_NoTransactionRunsOutsideTx(t *testing.T) {
+	m, mock := noTxHelper(t)
+
+	// no Begin before the index: it runs directly, then gets recorded
+	mock.ExpectExec(`CREATE INDEX CONCURRENTLY qwe_id ON qwe\(id\);`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(markArgs(2, "index")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	_, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_NoTransactionFailureIsNotRecorded(t *testing.T) {
+	m, mock := noTxHelper(t)
+
+	mock.ExpectExec(`CREATE INDEX CONCURRENTLY`).WillReturnError(errors.New("boom"))
+	expectUnlock(mock)
+
+	_, err := m.Up(context.Background())
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,103 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/hilltracer/gomigrator/internal/parser"
+)
+
+// Command names a mutating command that Plan can preview.
+type Command string
+
+const (
+	CommandUp     Command = "up"
+	CommandUpTo   Command = "up-to"
+	CommandDown   Command = "down"
+	CommandDownTo Command = "down-to"
+	CommandRedo   Command = "redo"
+)
+
+// PlanStep is one migration the previewed command would execute.
+type PlanStep struct {
+	Version       int64
+	Name          string
+	Path          string
+	Direction     Direction
+	NoTransaction bool
+	SQL           string // full Up or Down block; a placeholder for Go migrations
+}
+
+// Returns, in execution order, what cmd would execute right now without
+// executing anything. version is the target of CommandUpTo/CommandDownTo
+// and is ignored otherwise.
+func (m *Migrator) Plan(ctx context.Context, cmd Command, version int64) ([]PlanStep, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.store.AppliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		migs []parser.Migration
+		dir  = DirectionUp
+	)
+	switch cmd {
+	case CommandUp, CommandUpTo:
+		target := int64(math.MaxInt64)
+		if cmd == CommandUpTo {
+			if _, ok := indexByVersion(all)[version]; !ok {
+				return nil, fmt.Errorf("migration file for version %d not found", version)
+			}
+			target = version
+		}
+		migs = selectUp(all, applied, target, 0)
+		if err := m.checkMissing(migs, applied); err != nil {
+			return nil, err
+		}
+	case CommandDown, CommandDownTo, CommandRedo:
+		target, limit := int64(0), 1
+		if cmd == CommandDownTo {
+			target, limit = version, 0
+		}
+		migs, err = selectDown(all, applied, target, limit)
+		if err != nil {
+			return nil, err
+		}
+		dir = DirectionDown
+	default:
+		return nil, fmt.Errorf("cannot plan command %q", cmd)
+	}
+
+	plan := make([]PlanStep, 0, len(migs))
+	for _, mig := range migs {
+		plan = append(plan, newPlanStep(mig, dir))
+	}
+	if cmd == CommandRedo && len(migs) == 1 {
+		plan = append(plan, newPlanStep(migs[0], DirectionUp))
+	}
+	return plan, nil
+}
+
+func newPlanStep(mig parser.Migration, dir Direction) PlanStep {
+	sql, fn := mig.UpSQL, mig.UpFn
+	if dir == DirectionDown {
+		sql, fn = mig.DownSQL, mig.DownFn
+	}
+	if fn != nil {
+		sql = fmt.Sprintf("-- Go function (%s)", dir)
+	}
+	return PlanStep{
+		Version:       mig.Version,
+		Name:          mig.Name,
+		Path:          mig.Path,
+		Direction:     dir,
+		NoTransaction: mig.NoTransaction,
+		SQL:           strings.TrimSpace(sql),
+	}
+}
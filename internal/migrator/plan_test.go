@@ -0,0 +1,48 @@
+package migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan_RedoListsDownThenUp(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	m := New(sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42), writeMigrations(t, []int64{1, 2}))
+
+	mock.ExpectQuery("SELECT version, is_applied FROM \"gomigrator_schema_migrations\"").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "is_applied"}).AddRow(int64(1), true))
+
+	plan, err := m.Plan(context.Background(), CommandRedo, 0)
+	require.NoError(t, err)
+	require.Len(t, plan, 2)
+	require.Equal(t, DirectionDown, plan[0].Direction)
+	require.Equal(t, "DROP TABLE t1;", plan[0].SQL)
+	require.Equal(t, DirectionUp, plan[1].Direction)
+	require.Equal(t, "CREATE TABLE t1(id INT);", plan[1].SQL)
+	require.NoError(t, mock.ExpectationsWereMet()) // no lock, no transaction
+}
+
+func TestUp_DryRunRollsBack(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2})
+	m.WithOptions(Options{DryRun: true, TxMode: TxModePerMigration})
+
+	// one transaction for both despite per-migration mode, then rollback
+	mock.ExpectExec(`CREATE TABLE t1\(id INT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectExec(`CREATE TABLE t2\(id INT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	_, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,184 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hilltracer/gomigrator/internal/parser"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jmoiron/sqlx"
+)
+
+// Force records version as applied (it must have a file) or removes its
+// record, without running any SQL. Meant for fixing the history by hand,
+// e.g. after a NoTransaction migration failed halfway. Forcing an applied
+// version applied again, or a version without a record unapplied, changes
+// nothing, so records keep when and by whom they really ran. Returns the
+// recorded step, if any.
+func (m *Migrator) Force(ctx context.Context, version int64, applied bool) ([]Step, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	mig, ok := indexByVersion(all)[version]
+	if !ok {
+		if applied {
+			return nil, fmt.Errorf("migration file for version %d not found", version)
+		}
+		mig = parser.Migration{Version: version}
+	}
+	dir := DirectionDown
+	if applied {
+		dir = DirectionUp
+	}
+	var steps []Step
+	err = m.store.WithLock(ctx, func(sess *sqlstorage.Session) error {
+		records, err := sess.Records(ctx)
+		if err != nil {
+			return err
+		}
+		rec := findRecord(records, version)
+		if (applied && rec != nil && rec.IsApplied) || (!applied && rec == nil) {
+			return nil
+		}
+		if mig.Name == "" { // the file is gone; the record still knows
+			mig.Name = rec.Name
+		}
+		err = m.inTx(ctx, sess, func(tx *sqlx.Tx) error {
+			var err error
+			if applied {
+				err = m.store.MarkApplied(ctx, tx, mig.Version, mig.Name, mig.Checksum(), 0)
+			} else {
+				err = m.store.MarkRolledBack(ctx, tx, version)
+			}
+			if err != nil {
+				return err
+			}
+			return m.recordEvent(ctx, tx, mig, EventForce, dir, 0)
+		})
+		if err == nil {
+			steps = []Step{newStep(mig, dir)}
+		}
+		return err
+	})
+	return steps, err
+}
+
+// Returns the record of version, or nil if there is none.
+func findRecord(records []sqlstorage.Record, version int64) *sqlstorage.Record {
+	for i := range records {
+		if records[i].Version == version {
+			return &records[i]
+		}
+	}
+	return nil
+}
+
+// RepairReport lists what Repair changes (or changed) in the meta table.
+type RepairReport struct {
+	Removed []StatusEntry // rows whose files no longer exist
+	Updated []Drift       // applied rows whose checksum is rewritten to Actual
+}
+
+// Returns what Repair would change right now, without changing anything.
+func (m *Migrator) RepairPlan(ctx context.Context) (RepairReport, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return RepairReport{}, err
+	}
+	records, err := m.store.Records(ctx)
+	if err != nil {
+		return RepairReport{}, err
+	}
+	return m.planRepair(all, records)
+}
+
+// Repair removes meta rows whose files no longer exist and stores the
+// current checksum of every applied file, under the lock and in one
+// transaction. Each change is appended to the history as a repair event.
+// Returns what was changed.
+func (m *Migrator) Repair(ctx context.Context) (RepairReport, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return RepairReport{}, err
+	}
+	byVersion := indexByVersion(all)
+	var report RepairReport
+	err = m.store.WithLock(ctx, func(sess *sqlstorage.Session) error {
+		records, err := sess.Records(ctx)
+		if err != nil {
+			return err
+		}
+		report, err = m.planRepair(all, records)
+		if err != nil {
+			return err
+		}
+		return m.inTx(ctx, sess, func(tx *sqlx.Tx) error {
+			for _, e := range report.Removed {
+				if err := m.store.MarkRolledBack(ctx, tx, e.Version); err != nil {
+					return fmt.Errorf("remove %d_%s: %w", e.Version, e.Name, err)
+				}
+				gone := parser.Migration{Version: e.Version, Name: e.Name}
+				if err := m.recordEvent(ctx, tx, gone, EventRepair, DirectionDown, 0); err != nil {
+					return err
+				}
+			}
+			for _, d := range report.Updated {
+				if err := m.store.UpdateChecksum(ctx, tx, d.Version, d.Actual); err != nil {
+					return fmt.Errorf("checksum %d_%s: %w", d.Version, d.Name, err)
+				}
+				if err := m.recordEvent(ctx, tx, byVersion[d.Version], EventRepair, DirectionUp, 0); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return RepairReport{}, err
+	}
+	return report, nil
+}
+
+// ErrRepairRemovesAll means no meta row has a migration file left, which
+// is more likely a wrong migrations dir than a history to throw away.
+var ErrRepairRemovesAll = errors.New("repair would remove every migration record")
+
+// Unlike findDrifts, rows recorded before checksums existed are updated too.
+// Refuses to run without any migrations, and to remove every record unless
+// Options.AllowRemoveAll is set.
+func (m *Migrator) planRepair(all []parser.Migration, records []sqlstorage.Record) (RepairReport, error) {
+	if len(all) == 0 {
+		return RepairReport{}, errors.New("no migrations found: refusing to repair, check the migrations dir")
+	}
+	byVersion := indexByVersion(all)
+
+	var report RepairReport
+	for _, r := range records {
+		mig, ok := byVersion[r.Version]
+		if !ok {
+			report.Removed = append(report.Removed, StatusEntry{
+				Version:   r.Version,
+				Name:      r.Name,
+				State:     StateMissingFile,
+				IsApplied: r.IsApplied,
+				AppliedAt: r.AppliedAt,
+			})
+			continue
+		}
+		if sum := mig.Checksum(); r.IsApplied && sum != r.Checksum {
+			report.Updated = append(report.Updated, Drift{
+				Version:  mig.Version,
+				Name:     mig.Name,
+				Path:     mig.Path,
+				Recorded: r.Checksum,
+				Actual:   sum,
+			})
+		}
+	}
+	if len(records) > 0 && len(report.Removed) == len(records) && !m.opts.AllowRemoveAll {
+		return RepairReport{}, fmt.Errorf("%w: none of the %d has a migration file", ErrRepairRemovesAll, len(records))
+	}
+	return report, nil
+}
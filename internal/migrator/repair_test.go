@@ -0,0 +1,165 @@
+package migrator
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForce_MarksWithoutRunningSQL(t *testing.T) {
+	dir := writeMigrations(t, []int64{1, 2})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	m := New(sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42), dir)
+	records := func() *sqlmock.Rows {
+		return sqlmock.NewRows(recordColumns).
+			AddRow(int64(1), "t1", true, time.Now(), "", int64(0), "", "", "").
+			AddRow(int64(7), "old", true, time.Now(), "", int64(0), "", "", "")
+	}
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").WillReturnRows(records())
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "gomigrator_schema_migrations"`).
+		WithArgs(markArgs(2, "t2")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	expectUnlock(mock)
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").WillReturnRows(records())
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "gomigrator_schema_migrations" WHERE version = \$1`).
+		WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "gomigrator_history"`).
+		WithArgs(int64(7), "old", "force", "down", "", int64(0),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	steps, err := m.Force(context.Background(), 2, true)
+	require.NoError(t, err)
+	require.Equal(t, []Step{{Version: 2, Name: "t2", Direction: DirectionUp}}, steps)
+	// a version without a file can still be forced down; its name comes
+	// from the record
+	steps, err = m.Force(context.Background(), 7, false)
+	require.NoError(t, err)
+	require.Equal(t, []Step{{Version: 7, Name: "old", Direction: DirectionDown}}, steps)
+	_, err = m.Force(context.Background(), 7, true)
+	require.ErrorContains(t, err, "version 7 not found")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForce_ChangesNothingWhenAlreadyInState(t *testing.T) {
+	dir := writeMigrations(t, []int64{1, 2})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	m := New(sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42), dir)
+
+	// no upsert, delete or history event: an applied row keeps its real
+	// applied_at, and a missing one is not reported as forced down
+	for range 2 {
+		mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").
+			WillReturnRows(sqlmock.NewRows(recordColumns).
+				AddRow(int64(1), "t1", true, time.Now(), "", int64(0), "", "", "").
+				AddRow(int64(2), "t2", true, time.Now(), "", int64(0), "", "", ""))
+		expectUnlock(mock)
+	}
+
+	steps, err := m.Force(context.Background(), 2, true)
+	require.NoError(t, err)
+	require.Empty(t, steps)
+	steps, err = m.Force(context.Background(), 9, false)
+	require.NoError(t, err)
+	require.Empty(t, steps)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepair_RemovesOrphansAndRewritesChecksums(t *testing.T) {
+	dir := writeMigrations(t, []int64{1, 2})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	m := New(sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42), dir)
+	all, err := m.migrations()
+	require.NoError(t, err)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").
+		WillReturnRows(sqlmock.NewRows(recordColumns).
+			AddRow(int64(1), "t1", true, time.Now(), all[0].Checksum(), int64(0), "", "", "").
+			AddRow(int64(2), "t2", true, time.Now(), "", int64(0), "", "", "").
+			AddRow(int64(3), "gone", true, time.Now(), "old", int64(0), "", "", ""))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "gomigrator_schema_migrations"`).
+		WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "gomigrator_history"`).
+		WithArgs(int64(3), "gone", "repair", "down", "", int64(0),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "gomigrator_schema_migrations" SET checksum = \$1 WHERE version = \$2`).
+		WithArgs(all[1].Checksum(), int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "gomigrator_history"`).
+		WithArgs(int64(2), "t2", "repair", "up", all[1].Checksum(), int64(0),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	report, err := m.Repair(context.Background())
+	require.NoError(t, err)
+	require.Len(t, report.Removed, 1)
+	require.Equal(t, "gone", report.Removed[0].Name)
+	require.Len(t, report.Updated, 1)
+	require.Equal(t, int64(2), report.Updated[0].Version)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepair_RefusesWithoutMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	m := New(sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42), t.TempDir())
+
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").
+		WillReturnRows(sqlmock.NewRows(recordColumns).
+			AddRow(int64(1), "t1", true, time.Now(), "", int64(0), "", "", ""))
+
+	_, err = m.RepairPlan(context.Background())
+	require.ErrorContains(t, err, "no migrations found")
+	_, err = New(m.store, filepath.Join(t.TempDir(), "mgrations")).RepairPlan(context.Background())
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepair_RemovingEveryRecordNeedsAllowRemoveAll(t *testing.T) {
+	dir := writeMigrations(t, []int64{5})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	m := New(sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42), dir)
+	records := func() *sqlmock.Rows {
+		return sqlmock.NewRows(recordColumns).
+			AddRow(int64(1), "t1", true, time.Now(), "", int64(0), "", "", "").
+			AddRow(int64(2), "t2", true, time.Now(), "", int64(0), "", "", "")
+	}
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").WillReturnRows(records())
+	expectUnlock(mock)
+
+	_, err = m.Repair(context.Background())
+	require.ErrorIs(t, err, ErrRepairRemovesAll)
+
+	m.WithOptions(Options{AllowRemoveAll: true})
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").WillReturnRows(records())
+	report, err := m.RepairPlan(context.Background())
+	require.NoError(t, err)
+	require.Len(t, report.Removed, 2)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,43 @@
+package migrator
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpDown_AgainstSQLiteFile(t *testing.T) {
+	ctx := context.Background()
+	dir := writeMigrations(t, []int64{1, 2})
+	m, err := NewFromDSN(ctx, "sqlite://"+filepath.Join(t.TempDir(), "dev.db"), dir, sqlstorage.Options{})
+	require.NoError(t, err)
+	defer m.Close()
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	v, err := m.DBVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), v)
+
+	steps, err := m.DownN(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []Step{{Version: 2, Name: "t2", Direction: DirectionDown}}, steps)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.Equal(t, StateApplied, statuses[0].State)
+	require.Equal(t, StatePending, statuses[1].State)
+
+	// the history keeps the rolled-back migration
+	history, err := m.History(ctx)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, EventDown, history[2].Kind)
+	require.Equal(t, int64(2), history[2].Version)
+	require.NotEmpty(t, history[2].Checksum)
+	require.False(t, history[2].At.IsZero())
+}
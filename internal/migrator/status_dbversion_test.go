@@ -2,8 +2,11 @@ package migrator
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/hilltracer/gomigrator/internal/sqlstorage"
@@ -11,36 +14,53 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestStatus_ReturnsSortedVersionsAndMap(t *testing.T) {
+func TestStatus_MergesFilesWithDBRows(t *testing.T) {
 	ctx := context.Background()
 
+	// one applied file, one pending file; the DB also remembers a file that is gone
+	dir := t.TempDir()
+	body := []byte("-- +gomigrator Up\nSELECT 1;\n-- +gomigrator Down\nSELECT 1;\n")
+	appliedPath := filepath.Join(dir, "20240102030405_first.sql")
+	pendingPath := filepath.Join(dir, "20260102030405_third.sql")
+	require.NoError(t, os.WriteFile(appliedPath, body, 0o644))
+	require.NoError(t, os.WriteFile(pendingPath, body, 0o644))
+
 	// mock DB
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
 	require.NoError(t, err)
 	dbx := sqlx.NewDb(db, "gomigrator")
 
 	store := sqlstorage.NewWithMock(dbx, 42)
-	m := New(store, t.TempDir())
-
-	// unsorted rows → Status must sort them
-	vOld := StatusEntry{int64(20240102030405), true}
-	vNew := StatusEntry{int64(20250102030405), true}
+	m := New(store, dir)
 
+	appliedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
 	rows := sqlmock.
-		NewRows([]string{"version", "is_applied"}).
-		AddRow(vNew.Version, vNew.IsApplied).
-		AddRow(vOld.Version, vOld.IsApplied)
+		NewRows(recordColumns).
+		AddRow(int64(20250102030405), "second", true, appliedAt, "", int64(0), "", "", "").
+		AddRow(int64(20240102030405), "first", true, appliedAt, "", int64(1250), "deploy", "ci-7", "v1.4.0")
 
-	mock.ExpectQuery("SELECT version, is_applied FROM gomigrator_schema_migrations").
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM \"gomigrator_schema_migrations\"").
 		WillReturnRows(rows)
 
 	statuses, err := m.Status(ctx)
 	require.NoError(t, err)
 
 	require.True(t, sort.SliceIsSorted(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version }))
-	require.Equal(t, []StatusEntry{vOld, vNew}, statuses)
-	require.True(t, vNew.IsApplied)
-	require.True(t, vNew.IsApplied)
+	require.Equal(t, []StatusEntry{
+		{
+			Version: 20240102030405, Name: "first", Path: appliedPath,
+			State: StateApplied, IsApplied: true, AppliedAt: appliedAt,
+			HasRunInfo: true, Duration: 1250 * time.Millisecond, AppliedBy: "deploy@ci-7", ToolVersion: "v1.4.0",
+		},
+		{
+			Version: 20250102030405, Name: "second",
+			State: StateMissingFile, IsApplied: true, AppliedAt: appliedAt,
+		},
+		{
+			Version: 20260102030405, Name: "third", Path: pendingPath,
+			State: StatePending,
+		},
+	}, statuses)
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
@@ -63,7 +83,7 @@ func TestDBVersion_ReturnsHighestAppliedVersion(t *testing.T) {
 		AddRow(highestApplied, true).
 		AddRow(higherButNotApplied, false)
 
-	mock.ExpectQuery("SELECT version, is_applied FROM gomigrator_schema_migrations").
+	mock.ExpectQuery("SELECT version, is_applied FROM \"gomigrator_schema_migrations\"").
 		WillReturnRows(rows)
 
 	got, err := m.DBVersion(ctx)
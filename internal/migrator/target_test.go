@@ -0,0 +1,203 @@
+package migrator
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// multiHelper writes one migration per version (creating table t<version>)
+// and returns a Migrator backed by sqlmock with the given versions applied.
+func multiHelper(t *testing.T, versions []int64, applied ...int64) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+
+	dir := writeMigrations(t, versions)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	store := sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42)
+
+	rows := sqlmock.NewRows([]string{"version", "is_applied"})
+	for _, v := range applied {
+		rows.AddRow(v, true)
+	}
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT version, is_applied FROM \"gomigrator_schema_migrations\"").
+		WillReturnRows(rows)
+	mock.ExpectBegin()
+
+	return New(store, dir), mock
+}
+
+// Columns of the meta table as Records selects them.
+var recordColumns = []string{"version", "name", "is_applied", "applied_at", "checksum",
+	"duration_ms", "os_user", "hostname", "tool_version"}
+
+// markArgs matches the args of MarkApplied for version and name.
+func markArgs(version int64, name string) []driver.Value {
+	return []driver.Value{version, name, sqlmock.AnyArg(), sqlmock.AnyArg(),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()}
+}
+
+// writeMigrations creates t<version> migrations in a temp dir and returns it.
+func writeMigrations(t *testing.T, versions []int64) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, v := range versions {
+		body := fmt.Sprintf("-- +gomigrator Up\nCREATE TABLE t%d(id INT);\n"+
+			"-- +gomigrator Down\nDROP TABLE t%d;\n", v, v)
+		file := filepath.Join(dir, fmt.Sprintf("%d_t%d.sql", v, v))
+		require.NoError(t, os.WriteFile(file, []byte(body), 0o644))
+	}
+	return dir
+}
+
+func expectHistory(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`INSERT INTO "gomigrator_history"`).WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestUpTo_StopsAtTarget(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2, 3}, 1)
+
+	mock.ExpectExec(`CREATE TABLE t2\(id INT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(markArgs(2, "t2")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	steps, err := m.UpTo(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, []Step{{Version: 2, Name: "t2", Direction: DirectionUp}}, steps)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpTo_UnknownVersion(t *testing.T) {
+	dir := t.TempDir()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	m := New(sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42), dir)
+
+	_, err = m.UpTo(context.Background(), 7)
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownTo_RollsBackNewestFirst(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2, 3}, 1, 2, 3)
+
+	mock.ExpectExec(`DROP TABLE t3;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM \"gomigrator_schema_migrations\"").
+		WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectExec(`DROP TABLE t2;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM \"gomigrator_schema_migrations\"").
+		WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	steps, err := m.DownTo(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, []Step{
+		{Version: 3, Name: "t3", Direction: DirectionDown},
+		{Version: 2, Name: "t2", Direction: DirectionDown},
+	}, steps)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpByOne_AppliesOnlyNext(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2, 3}, 1)
+
+	mock.ExpectExec(`CREATE TABLE t2\(id INT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(markArgs(2, "t2")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	steps, err := m.UpByOne(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Step{{Version: 2, Name: "t2", Direction: DirectionUp}}, steps)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownN_RollsBackN(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2, 3}, 1, 2, 3)
+
+	mock.ExpectExec(`DROP TABLE t3;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM \"gomigrator_schema_migrations\"").
+		WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectExec(`DROP TABLE t2;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM \"gomigrator_schema_migrations\"").
+		WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	steps, err := m.DownN(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, []Step{
+		{Version: 3, Name: "t3", Direction: DirectionDown},
+		{Version: 2, Name: "t2", Direction: DirectionDown},
+	}, steps)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReset_RollsBackEverything(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2}, 1, 2)
+
+	mock.ExpectExec(`DROP TABLE t2;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM \"gomigrator_schema_migrations\"").
+		WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectExec(`DROP TABLE t1;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM \"gomigrator_schema_migrations\"").
+		WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	steps, err := m.Reset(context.Background())
+	require.NoError(t, err)
+	require.Len(t, steps, 2)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_PerMigrationReportsCommitted(t *testing.T) {
+	m, mock := multiHelper(t, []int64{1, 2, 3})
+	m.WithOptions(Options{TxMode: TxModePerMigration})
+
+	// multiHelper already expects the first Begin
+	mock.ExpectExec(`CREATE TABLE t1\(id INT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(markArgs(1, "t1")...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHistory(mock)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE t2\(id INT\);`).WillReturnError(errors.New("boom"))
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	_, err := m.Up(context.Background())
+	var pe *PartialError
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, []Step{{Version: 1, Name: "t1", Direction: DirectionUp}}, pe.Committed)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
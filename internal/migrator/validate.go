@@ -0,0 +1,77 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hilltracer/gomigrator/internal/parser"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+)
+
+// ErrChecksumDrift is returned by up-style commands when FailOnDrift is set
+// and an applied migration file no longer matches its recorded checksum.
+var ErrChecksumDrift = errors.New("checksum drift detected")
+
+// Drift describes an applied migration whose file changed after it was applied.
+type Drift struct {
+	Version  int64
+	Name     string
+	Path     string
+	Recorded string // checksum stored in the DB
+	Actual   string // checksum of the file on disk
+}
+
+// Returns every applied migration whose file checksum differs from the DB.
+// Rows recorded before checksums existed are skipped.
+func (m *Migrator) Validate(ctx context.Context) ([]Drift, error) {
+	all, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+	records, err := m.store.Records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return findDrifts(all, records), nil
+}
+
+func findDrifts(all []parser.Migration, records []sqlstorage.Record) []Drift {
+	byVersion := indexByVersion(all)
+
+	var res []Drift
+	for _, r := range records {
+		mig, ok := byVersion[r.Version]
+		if !ok || !r.IsApplied || r.Checksum == "" {
+			continue
+		}
+		if sum := mig.Checksum(); sum != r.Checksum {
+			res = append(res, Drift{
+				Version:  mig.Version,
+				Name:     mig.Name,
+				Path:     mig.Path,
+				Recorded: r.Checksum,
+				Actual:   sum,
+			})
+		}
+	}
+	return res
+}
+
+// Fails with ErrChecksumDrift if any applied file changed; runs under the lock.
+func (m *Migrator) checkDrift(ctx context.Context, sess *sqlstorage.Session, all []parser.Migration) error {
+	records, err := sess.Records(ctx)
+	if err != nil {
+		return err
+	}
+	drifts := findDrifts(all, records)
+	if len(drifts) == 0 {
+		return nil
+	}
+	names := make([]string, len(drifts))
+	for i, d := range drifts {
+		names[i] = fmt.Sprintf("%d_%s", d.Version, d.Name)
+	}
+	return fmt.Errorf("%w: %s", ErrChecksumDrift, strings.Join(names, ", "))
+}
@@ -0,0 +1,77 @@
+package migrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hilltracer/gomigrator/internal/parser"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// driftHelper writes two applied migrations; the DB remembers the original
+// checksum of the first one and a stale checksum for the second one.
+func driftHelper(t *testing.T) (*Migrator, sqlmock.Sqlmock, *sqlmock.Rows) {
+	t.Helper()
+
+	dir := t.TempDir()
+	body := "-- +gomigrator Up\nSELECT 1;\n-- +gomigrator Down\nSELECT 2;\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1_same.sql"), []byte(body), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2_edited.sql"), []byte(body), 0o644))
+	sum := parser.Migration{UpSQL: "SELECT 1;", DownSQL: "SELECT 2;"}.Checksum()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	m := New(sqlstorage.NewWithMock(sqlx.NewDb(db, "gomigrator"), 42), dir)
+
+	rows := sqlmock.NewRows(recordColumns).
+		AddRow(int64(1), "same", true, time.Now(), sum, int64(0), "", "", "").
+		AddRow(int64(2), "edited", true, time.Now(), "stale", int64(0), "", "", "")
+	return m, mock, rows
+}
+
+func TestValidate_ReportsDrift(t *testing.T) {
+	m, mock, rows := driftHelper(t)
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").
+		WillReturnRows(rows)
+
+	drifts, err := m.Validate(context.Background())
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	require.Equal(t, int64(2), drifts[0].Version)
+	require.Equal(t, "stale", drifts[0].Recorded)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatus_MarksEditedFilesDrifted(t *testing.T) {
+	m, mock, rows := driftHelper(t)
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").
+		WillReturnRows(rows)
+
+	statuses, err := m.Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.Equal(t, StateApplied, statuses[0].State)
+	require.Equal(t, StateDrifted, statuses[1].State)
+	require.True(t, statuses[1].IsApplied)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_FailOnDriftRefuses(t *testing.T) {
+	m, mock, rows := driftHelper(t)
+	m.WithOptions(Options{FailOnDrift: true})
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT version, name, is_applied, applied_at, checksum,.* FROM").
+		WillReturnRows(rows)
+	expectUnlock(mock)
+
+	_, err := m.Up(context.Background())
+	require.ErrorIs(t, err, ErrChecksumDrift)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
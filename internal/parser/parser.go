@@ -2,8 +2,15 @@ package parser
 
 import (
 	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -14,20 +21,71 @@ import (
 type Migration struct {
 	Version int64
 	Name    string
+	Path    string
 	UpSQL   string
 	DownSQL string
+
+	// UpSQL and DownSQL split into individual statements, in execution order.
+	UpStatements   []string
+	DownStatements []string
+
+	// UpFn and DownFn are set for Go migrations registered in code;
+	// they run inside the migration transaction instead of SQL.
+	UpFn   GoFunc
+	DownFn GoFunc
+
+	// NoTransaction is set by the `-- +gomigrator NoTransaction` annotation:
+	// the file is executed outside a transaction (e.g. CREATE INDEX CONCURRENTLY).
+	NoTransaction bool
 }
 
+// GoFunc is the body of a migration written in Go.
+type GoFunc func(ctx context.Context, tx *sql.Tx) error
+
 // ParseDir walks `dir` and returns all recognised migrations, sorted by Version.
-func ParseDir(dir string) ([]Migration, error) {
-	list, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+// Files are split into statements with SyntaxPostgres.
+func ParseDir(dir string) ([]Migration, error) { return ParseDirSyntax(dir, SyntaxPostgres) }
+
+// ParseFS walks `dir` inside fsys (e.g. an embed.FS) and returns all
+// recognised migrations, sorted by Version. Paths are slash-separated
+// and relative to the root of fsys. Files are split with SyntaxPostgres.
+func ParseFS(fsys fs.FS, dir string) ([]Migration, error) {
+	return ParseFSSyntax(fsys, dir, SyntaxPostgres)
+}
+
+// Like ParseDir, but splits statements by the rules of syntax.
+// An empty dir means the current directory; a missing one is an error.
+func ParseDirSyntax(dir string, syntax Syntax) ([]Migration, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if err := checkDir(os.Stat(dir)); err != nil {
+		return nil, fmt.Errorf("migrations dir %s: %w", dir, err)
+	}
+	m, err := ParseFSSyntax(os.DirFS(dir), ".", syntax)
+	if err != nil {
+		return nil, err
+	}
+	for i := range m { // report OS paths rather than paths inside the FS
+		m[i].Path = filepath.Join(dir, filepath.FromSlash(m[i].Path))
+	}
+	return m, nil
+}
+
+// Like ParseFS, but splits statements by the rules of syntax.
+func ParseFSSyntax(fsys fs.FS, dir string, syntax Syntax) ([]Migration, error) {
+	// fs.Glob ignores I/O errors, so a missing dir would look empty
+	if err := checkDir(fs.Stat(fsys, dir)); err != nil {
+		return nil, fmt.Errorf("migrations dir %s: %w", dir, err)
+	}
+	list, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
 	if err != nil {
 		return nil, err
 	}
 
 	m := make([]Migration, 0, len(list))
 	for _, f := range list {
-		mig, err := parseFile(f)
+		mig, err := parseFile(fsys, f, syntax)
 		if err != nil {
 			return nil, fmt.Errorf("file %s: %w", f, err)
 		}
@@ -38,8 +96,16 @@ func ParseDir(dir string) ([]Migration, error) {
 	return m, nil
 }
 
-func parseFile(path string) (Migration, error) {
-	fn := filepath.Base(path) // 20250713190900_init.sql
+// Returns the error of a Stat call, or one if it found a file, not a dir.
+func checkDir(fi fs.FileInfo, err error) error {
+	if err == nil && !fi.IsDir() {
+		return errors.New("not a directory")
+	}
+	return err
+}
+
+func parseFile(fsys fs.FS, name string, syntax Syntax) (Migration, error) {
+	fn := path.Base(name) // 20250713190900_init.sql
 	parts := strings.SplitN(fn, "_", 2)
 	if len(parts) != 2 {
 		return Migration{}, fmt.Errorf("filename must be <version>_<name>.sql")
@@ -48,18 +114,19 @@ func parseFile(path string) (Migration, error) {
 	if err != nil {
 		return Migration{}, fmt.Errorf("invalid version prefix: %w", err)
 	}
-	name := strings.TrimSuffix(parts[1], ".sql")
+	migName := strings.TrimSuffix(parts[1], ".sql")
 
-	f, err := os.Open(path)
+	f, err := fsys.Open(name)
 	if err != nil {
 		return Migration{}, err
 	}
 	defer f.Close()
 
 	var (
-		cur  *strings.Builder
-		up   strings.Builder
-		down strings.Builder
+		cur   *section
+		up    = section{syntax: syntax}
+		down  = section{syntax: syntax}
+		noTxn bool
 	)
 
 	sc := bufio.NewScanner(f)
@@ -72,19 +139,123 @@ func parseFile(path string) (Migration, error) {
 		case "-- +gomigrator Down":
 			cur = &down
 			continue
+		case "-- +gomigrator NoTransaction":
+			noTxn = true
+			continue
+		case "-- +gomigrator StatementBegin":
+			if err := cur.begin(); err != nil {
+				return Migration{}, err
+			}
+			continue
+		case "-- +gomigrator StatementEnd":
+			if err := cur.end(); err != nil {
+				return Migration{}, err
+			}
+			continue
 		}
 		if cur != nil {
-			cur.WriteString(line)
-			cur.WriteByte('\n')
+			cur.writeLine(line)
 		}
 	}
 	if err := sc.Err(); err != nil {
 		return Migration{}, err
 	}
+	for _, sec := range []*section{&up, &down} {
+		if err := sec.finish(); err != nil {
+			return Migration{}, err
+		}
+	}
 	return Migration{
 		Version: ver,
-		Name:    name,
-		UpSQL:   strings.TrimSpace(up.String()),
-		DownSQL: strings.TrimSpace(down.String()),
+		Name:    migName,
+		Path:    name,
+		UpSQL:   strings.TrimSpace(up.raw.String()),
+		DownSQL: strings.TrimSpace(down.raw.String()),
+
+		UpStatements:   up.stmts,
+		DownStatements: down.stmts,
+
+		NoTransaction: noTxn,
 	}, nil
 }
+
+// section accumulates the Up or the Down block of a file.
+type section struct {
+	syntax Syntax
+	raw    strings.Builder  // the whole block
+	plain  strings.Builder  // text not split into statements yet
+	block  *strings.Builder // non-nil between StatementBegin and StatementEnd
+	stmts  []string
+}
+
+func (s *section) writeLine(line string) {
+	s.raw.WriteString(line)
+	s.raw.WriteByte('\n')
+	if s.block != nil {
+		s.block.WriteString(line)
+		s.block.WriteByte('\n')
+		return
+	}
+	s.plain.WriteString(line)
+	s.plain.WriteByte('\n')
+}
+
+// Starts an explicit statement that is executed as a whole.
+func (s *section) begin() error {
+	if s == nil {
+		return fmt.Errorf("StatementBegin outside of Up/Down block")
+	}
+	if s.block != nil {
+		return fmt.Errorf("nested StatementBegin")
+	}
+	s.flush()
+	s.block = &strings.Builder{}
+	return nil
+}
+
+func (s *section) end() error {
+	if s == nil || s.block == nil {
+		return fmt.Errorf("StatementEnd without StatementBegin")
+	}
+	if stmt := strings.TrimSpace(s.block.String()); stmt != "" {
+		s.stmts = append(s.stmts, stmt)
+	}
+	s.block = nil
+	return nil
+}
+
+func (s *section) finish() error {
+	if s.block != nil {
+		return fmt.Errorf("StatementBegin without StatementEnd")
+	}
+	s.flush()
+	return nil
+}
+
+func (s *section) flush() {
+	s.stmts = append(s.stmts, splitStatements(s.plain.String(), s.syntax)...)
+	s.plain.Reset()
+}
+
+// Checksum returns a SHA-256 hex digest of the normalized Up and Down SQL.
+// Line endings, trailing whitespace and blank lines do not affect it.
+func (m Migration) Checksum() string {
+	h := sha256.New()
+	h.Write([]byte(normalizeSQL(m.UpSQL)))
+	h.Write([]byte("\n-- +gomigrator Down\n"))
+	h.Write([]byte(normalizeSQL(m.DownSQL)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func normalizeSQL(sql string) string {
+	lines := strings.Split(strings.ReplaceAll(sql, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
@@ -1,9 +1,11 @@
 package parser
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/require"
 )
@@ -35,3 +37,68 @@ func TestParseDir_BadFilename(t *testing.T) {
 	_, err := ParseDir(tmp)
 	require.Error(t, err)
 }
+
+func TestChecksum_IgnoresFormattingNoise(t *testing.T) {
+	a := Migration{UpSQL: "CREATE TABLE qwe(id INT);\n\nSELECT 1;", DownSQL: "DROP TABLE qwe;"}
+	b := Migration{UpSQL: "CREATE TABLE qwe(id INT);  \r\nSELECT 1;", DownSQL: "DROP TABLE qwe;\n"}
+	c := Migration{UpSQL: "CREATE TABLE qwe(id BIGINT);\nSELECT 1;", DownSQL: "DROP TABLE qwe;"}
+
+	require.Equal(t, a.Checksum(), b.Checksum())
+	require.NotEqual(t, a.Checksum(), c.Checksum())
+	require.Len(t, a.Checksum(), 64)
+}
+
+func TestParseDir_NoTransaction(t *testing.T) {
+	tmp := t.TempDir()
+	sql := `-- +gomigrator NoTransaction
+-- +gomigrator Up
+CREATE INDEX CONCURRENTLY qwe_id ON qwe(id);
+-- +gomigrator Down
+DROP INDEX CONCURRENTLY qwe_id;`
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "20250713101010_idx.sql"), []byte(sql), 0o644))
+
+	got, err := ParseDir(tmp)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.True(t, got[0].NoTransaction)
+	require.Equal(t, "CREATE INDEX CONCURRENTLY qwe_id ON qwe(id);", got[0].UpSQL)
+}
+
+func TestParseFS_SubDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/20250713101010_init.sql": {Data: []byte("-- +gomigrator Up\nSELECT 1;\n-- +gomigrator Down\nSELECT 2;")},
+		"migrations/README.md":               {Data: []byte("not a migration")},
+		"20250713101011_outside.sql":         {Data: []byte("-- +gomigrator Up\nSELECT 3;")},
+	}
+
+	got, err := ParseFS(fsys, "migrations")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "init", got[0].Name)
+	require.Equal(t, "migrations/20250713101010_init.sql", got[0].Path)
+	require.Equal(t, []string{"SELECT 1;"}, got[0].UpStatements)
+}
+
+func TestParseDir_EmptyMeansCurrentDir(t *testing.T) {
+	tmp := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "20250713101010_init.sql"),
+		[]byte("-- +gomigrator Up\nSELECT 1;"), 0o644))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(tmp))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	got, err := ParseDir("")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "20250713101010_init.sql", got[0].Path)
+}
+
+func TestParseDir_MissingDir(t *testing.T) {
+	_, err := ParseDir(filepath.Join(t.TempDir(), "mgrations"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorContains(t, err, "mgrations")
+
+	_, err = ParseFS(fstest.MapFS{}, "migrations")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,157 @@
+package parser
+
+import "strings"
+
+// Syntax selects the lexical rules used to split files into statements.
+type Syntax int
+
+const (
+	// SyntaxPostgres: '' escapes, E'' strings, $$ bodies, nested /* */.
+	// SQLite files split the same way.
+	SyntaxPostgres Syntax = iota
+	// SyntaxMySQL: backslash escapes in '' and "", # comments, `identifiers`.
+	SyntaxMySQL
+)
+
+// splitStatements splits sql into statements terminated by ';'.
+// Semicolons inside quoted strings, quoted identifiers, dollar-quoted bodies
+// and comments do not end a statement. Statements made only of comments
+// are dropped. The terminating ';' is kept.
+func splitStatements(sql string, syntax Syntax) []string {
+	var (
+		res  []string
+		cur  strings.Builder
+		code bool // cur holds something besides comments and whitespace
+	)
+	flush := func() {
+		if code {
+			res = append(res, strings.TrimSpace(cur.String()))
+		}
+		cur.Reset()
+		code = false
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		var end int // index right after the token starting at i
+		mysql := syntax == SyntaxMySQL
+		switch {
+		case isLineComment(sql[i:], syntax):
+			end = indexFrom(sql, i, "\n")
+		case strings.HasPrefix(sql[i:], "/*"):
+			end = blockCommentEnd(sql, i, !mysql)
+		case c == '\'':
+			end = quotedEnd(sql, i, '\'', mysql || isEscapeString(sql, i))
+			code = true
+		case c == '"':
+			end = quotedEnd(sql, i, '"', mysql)
+			code = true
+		case c == '`' && mysql:
+			end = quotedEnd(sql, i, '`', false)
+			code = true
+		case c == '$' && !mysql && dollarTag(sql[i:]) != "":
+			tag := dollarTag(sql[i:])
+			end = indexFrom(sql, i+len(tag), tag)
+			code = true
+		case c == ';':
+			cur.WriteByte(c)
+			flush()
+			i++
+			continue
+		default:
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				code = true
+			}
+			end = i + 1
+		}
+		cur.WriteString(sql[i:end])
+		i = end
+	}
+	flush()
+	return res
+}
+
+// Returns the index right after the first sep found at or after from,
+// or len(s) if there is none.
+func indexFrom(s string, from int, sep string) int {
+	idx := strings.Index(s[from:], sep)
+	if idx == -1 {
+		return len(s)
+	}
+	return from + idx + len(sep)
+}
+
+// Reports whether s starts with a comment running to the end of the line.
+// MySQL needs whitespace after "--" and also takes "#".
+func isLineComment(s string, syntax Syntax) bool {
+	if syntax != SyntaxMySQL {
+		return strings.HasPrefix(s, "--")
+	}
+	if strings.HasPrefix(s, "#") {
+		return true
+	}
+	return strings.HasPrefix(s, "--") && (len(s) == 2 || strings.ContainsRune(" \t\r\n", rune(s[2])))
+}
+
+// Returns the index right after the "*/" closing the comment at start,
+// or len(s) if there is none. Postgres block comments nest; MySQL ones do not.
+func blockCommentEnd(s string, start int, nested bool) int {
+	depth := 0
+	for i := start; i+1 < len(s); i++ {
+		switch {
+		case s[i] == '/' && s[i+1] == '*' && (nested || depth == 0):
+			depth++
+			i++
+		case s[i] == '*' && s[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// Returns the index right after the quote closing the one at start.
+// A doubled quote is an escaped quote; with backslash set, so is \'.
+func quotedEnd(s string, start int, quote byte, backslash bool) int {
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case backslash && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// Reports whether the quote at i opens an E'...' string with C-style escapes.
+func isEscapeString(s string, i int) bool {
+	if i == 0 || (s[i-1] != 'E' && s[i-1] != 'e') {
+		return false
+	}
+	return i == 1 || !isIdentChar(s[i-2])
+}
+
+// Returns the opening tag ($$ or $name$) at the start of s, or "".
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '$':
+			return s[:i+1]
+		case !isIdentChar(s[i]) || (i == 1 && s[i] >= '0' && s[i] <= '9'):
+			return "" // $1 is a placeholder, not a tag
+		}
+	}
+	return ""
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
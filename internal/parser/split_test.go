@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	sql := `-- leading comment; not a statement
+CREATE TABLE qwe(id INT, note TEXT DEFAULT 'a;b', "we;ird" INT);
+INSERT INTO qwe(note) VALUES ('it''s; fine'), (E'esc\'; still');
+/* block; comment */
+CREATE FUNCTION f() RETURNS INT AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql;
+SELECT $$;$$, $1
+-- trailing comment;`
+
+	got := splitStatements(sql, SyntaxPostgres)
+	require.Equal(t, []string{
+		"-- leading comment; not a statement\n" +
+			`CREATE TABLE qwe(id INT, note TEXT DEFAULT 'a;b', "we;ird" INT);`,
+		`INSERT INTO qwe(note) VALUES ('it''s; fine'), (E'esc\'; still');`,
+		"/* block; comment */\n" +
+			"CREATE FUNCTION f() RETURNS INT AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql;",
+		"SELECT $$;$$, $1\n-- trailing comment;",
+	}, got)
+}
+
+func TestSplitStatements_NestedBlockComments(t *testing.T) {
+	got := splitStatements("/* a /* b */ ; */ SELECT 1;\nSELECT 2;", SyntaxPostgres)
+	require.Equal(t, []string{"/* a /* b */ ; */ SELECT 1;", "SELECT 2;"}, got)
+}
+
+func TestSplitStatements_MySQL(t *testing.T) {
+	cases := []struct {
+		name, sql string
+		want      []string
+	}{
+		{"backslash escape", `INSERT INTO t VALUES ('it\'s; fine');`,
+			[]string{`INSERT INTO t VALUES ('it\'s; fine');`}},
+		{"backslash in double quotes", `SELECT "a\";b";`, []string{`SELECT "a\";b";`}},
+		{"hash comment", "# don't\nSELECT 1;\nSELECT 2;",
+			[]string{"# don't\nSELECT 1;", "SELECT 2;"}},
+		{"dash comment needs a space", "SELECT 1--1;\nSELECT 2;", []string{"SELECT 1--1;", "SELECT 2;"}},
+		{"backtick identifier", "CREATE TABLE `a;b` (`it's` INT);\nSELECT 1;",
+			[]string{"CREATE TABLE `a;b` (`it's` INT);", "SELECT 1;"}},
+		{"block comments do not nest", "/* a /* b */ SELECT 1; */", []string{"/* a /* b */ SELECT 1;", "*/"}},
+		{"no dollar quoting", "SELECT $$;\nSELECT 2;", []string{"SELECT $$;", "SELECT 2;"}},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.want, splitStatements(c.sql, SyntaxMySQL), c.name)
+	}
+}
+
+func TestParseDirSyntax_MySQL(t *testing.T) {
+	tmp := t.TempDir()
+	sql := "-- +gomigrator Up\n# don't split here\nINSERT INTO t VALUES ('a\\';b');\nSELECT 1;\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "1_esc.sql"), []byte(sql), 0o644))
+
+	got, err := ParseDirSyntax(tmp, SyntaxMySQL)
+	require.NoError(t, err)
+	require.Equal(t, []string{"# don't split here\nINSERT INTO t VALUES ('a\\';b');", "SELECT 1;"},
+		got[0].UpStatements)
+}
+
+func TestParseDir_StatementBlocks(t *testing.T) {
+	tmp := t.TempDir()
+	sql := `-- +gomigrator Up
+CREATE TABLE qwe(id INT);
+-- +gomigrator StatementBegin
+CREATE FUNCTION f() RETURNS INT AS '
+  SELECT 1;
+' LANGUAGE sql;
+-- +gomigrator StatementEnd
+INSERT INTO qwe VALUES (1);
+-- +gomigrator Down
+DROP FUNCTION f;
+DROP TABLE qwe;`
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "20250713101010_fn.sql"), []byte(sql), 0o644))
+
+	got, err := ParseDir(tmp)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, []string{
+		"CREATE TABLE qwe(id INT);",
+		"CREATE FUNCTION f() RETURNS INT AS '\n  SELECT 1;\n' LANGUAGE sql;",
+		"INSERT INTO qwe VALUES (1);",
+	}, got[0].UpStatements)
+	require.Equal(t, []string{"DROP FUNCTION f;", "DROP TABLE qwe;"}, got[0].DownStatements)
+}
+
+func TestParseDir_UnterminatedStatementBlock(t *testing.T) {
+	tmp := t.TempDir()
+	sql := "-- +gomigrator Up\n-- +gomigrator StatementBegin\nSELECT 1;\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "20250713101010_bad.sql"), []byte(sql), 0o644))
+
+	_, err := ParseDir(tmp)
+	require.Error(t, err)
+}
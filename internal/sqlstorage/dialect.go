@@ -0,0 +1,121 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect hides what differs between database engines: the driver, meta
+// table DDL, upserts, placeholders and how migrators lock each other out.
+type Dialect interface {
+	// Name used in config and DSN schemes, e.g. "postgres".
+	Name() string
+	// Name of the database/sql driver to open.
+	DriverName() string
+	// Upper bound for the connection pool; zero means no limit.
+	MaxOpenConns() int
+	// Reports whether DDL can be rolled back with its transaction.
+	TransactionalDDL() bool
+
+	QuoteIdent(name string) string
+	// Statements that create the meta table with its latest columns.
+	// schema is unquoted and may be empty; table is quoted and qualified.
+	MetaDDL(schema, table string) []string
+	// Statement adding a column named by a meta upgrade step to an older table.
+	AddColumnDDL(table, column string) string
+	// Statement that creates the append-only history table.
+	HistoryDDL(table string) string
+	// Query inserting or re-applying a row; args are version, name, checksum,
+	// duration_ms, os_user, hostname, tool_version.
+	UpsertApplied(table string) string
+	// Rewrites '?' placeholders into the dialect's own.
+	Rebind(query string) string
+
+	// Lock blocks until the migration lock is taken on conn.
+	Lock(ctx context.Context, conn *sqlx.Conn, id int64) error
+	// TryLock takes the lock if it is free and reports whether it did.
+	TryLock(ctx context.Context, conn *sqlx.Conn, id int64) (bool, error)
+	Unlock(ctx context.Context, conn *sqlx.Conn, id int64) error
+	// Describes who holds the lock, or "" if that is unknown.
+	LockHolder(ctx context.Context, conn *sqlx.Conn, id int64) string
+}
+
+// Returns the dialect named by driver, or, when driver is empty, by the DSN
+// scheme (sqlite://, sqlite:, mysql://, pgx://, postgres://); anything else
+// is Postgres on lib/pq.
+// The returned DSN is what the driver expects, with the scheme stripped
+// where the driver does not understand it.
+func DialectFor(driver, dsn string) (Dialect, string, error) {
+	if driver == "" {
+		for _, d := range dialects {
+			if rest, ok := d.strip(dsn); ok {
+				return d.dialect, rest, nil
+			}
+		}
+		return Postgres, dsn, nil
+	}
+	for _, d := range dialects {
+		if d.dialect.Name() == driver {
+			rest, _ := d.strip(dsn)
+			return d.dialect, rest, nil
+		}
+	}
+	return nil, "", fmt.Errorf("unknown driver %q", driver)
+}
+
+// Returns the dialect named by driver, or, when driver is empty, the one
+// whose driver db was opened with; unknown drivers are taken for Postgres.
+func DialectForDB(driver string, db *sql.DB) (Dialect, error) {
+	if driver != "" {
+		d, _, err := DialectFor(driver, "")
+		return d, err
+	}
+	switch db.Driver().(type) {
+	case *stdlib.Driver:
+		return PGX, nil
+	case *mysql.MySQLDriver:
+		return MySQL, nil
+	case *sqlite3.SQLiteDriver:
+		return SQLite, nil
+	}
+	return Postgres, nil
+}
+
+type dialectEntry struct {
+	dialect Dialect
+	schemes []string            // prefixes removed from the DSN
+	keepDSN bool                // the driver parses the scheme itself
+	fixDSN  func(string) string // adjusts the driver DSN, if set
+}
+
+var dialects = []dialectEntry{
+	{dialect: SQLite, schemes: []string{"sqlite3://", "sqlite://", "sqlite3:", "sqlite:"}},
+	{dialect: MySQL, schemes: []string{"mysql://"}, fixDSN: mysqlDSN},
+	{dialect: PGX, schemes: []string{"pgx://"}, keepDSN: true, fixDSN: pgxDSN},
+	{dialect: Postgres, schemes: []string{"postgres://", "postgresql://"}, keepDSN: true},
+}
+
+// Returns dsn without a known scheme and whether one was found.
+func (d dialectEntry) strip(dsn string) (string, bool) {
+	res, found := dsn, false
+	for _, scheme := range d.schemes {
+		if strings.HasPrefix(dsn, scheme) {
+			res, found = dsn, true
+			if !d.keepDSN {
+				res = strings.TrimPrefix(dsn, scheme)
+			}
+			break
+		}
+	}
+	if d.fixDSN != nil {
+		res = d.fixDSN(res)
+	}
+	return res, found
+}
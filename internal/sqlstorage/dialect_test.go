@@ -0,0 +1,171 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialectFor(t *testing.T) {
+	cases := []struct {
+		driver, dsn string
+		want        Dialect
+		wantDSN     string
+	}{
+		{"", "host=localhost dbname=app", Postgres, "host=localhost dbname=app"},
+		{"", "postgres://u@localhost/app", Postgres, "postgres://u@localhost/app"},
+		{"", "sqlite://dev.db", SQLite, "dev.db"},
+		{"", "sqlite:file:dev.db?cache=shared", SQLite, "file:dev.db?cache=shared"},
+		{"sqlite", "dev.db", SQLite, "dev.db"},
+		{"", "mysql://u:p@tcp(db:3306)/app", MySQL, "u:p@tcp(db:3306)/app?parseTime=true"},
+		{"postgres", "host=db", Postgres, "host=db"},
+		{"", "pgx://u@localhost/app", PGX, "postgres://u@localhost/app"},
+		{"pgx", "host=db dbname=app", PGX, "host=db dbname=app"},
+	}
+	for _, c := range cases {
+		d, dsn, err := DialectFor(c.driver, c.dsn)
+		require.NoError(t, err, c.dsn)
+		require.Equal(t, c.want.Name(), d.Name(), c.dsn)
+		require.Equal(t, c.wantDSN, dsn)
+	}
+
+	_, _, err := DialectFor("oracle", "x")
+	require.Error(t, err)
+}
+
+func TestSQLite_MetaTableRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s, err := Connect(ctx, "sqlite://"+filepath.Join(t.TempDir(), "dev.db"), Options{ToolVersion: "v1.2.3"})
+	require.NoError(t, err)
+	defer s.Close()
+
+	err = s.WithLock(ctx, func(sess *Session) error {
+		return sess.InTx(ctx, func(tx *sqlx.Tx) error {
+			if err := s.MarkApplied(ctx, tx, 1, "init", "sum1", 0); err != nil {
+				return err
+			}
+			if err := s.MarkApplied(ctx, tx, 2, "users", "sum2", time.Second); err != nil {
+				return err
+			}
+			// re-applying updates the checksum in place
+			return s.MarkApplied(ctx, tx, 2, "users", "sum3", 1500*time.Millisecond)
+		})
+	})
+	require.NoError(t, err)
+
+	recs, err := s.Records(ctx)
+	require.NoError(t, err)
+	require.Len(t, recs, 2)
+	require.Equal(t, "sum3", recs[1].Checksum)
+	require.True(t, recs[1].IsApplied)
+	require.False(t, recs[1].AppliedAt.IsZero())
+	require.Equal(t, int64(1500), recs[1].DurationMs)
+	require.Equal(t, "v1.2.3", recs[1].ToolVersion)
+	require.Equal(t, currentIdentity().user, recs[1].OSUser)
+
+	err = s.WithLock(ctx, func(sess *Session) error {
+		return sess.InTx(ctx, func(tx *sqlx.Tx) error {
+			return s.MarkRolledBack(ctx, tx, 2)
+		})
+	})
+	require.NoError(t, err)
+	applied, err := s.AppliedVersions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[int64]bool{1: true}, applied)
+}
+
+func TestDialectForDB_GuessesFromDriver(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "dev.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	d, err := DialectForDB("", db)
+	require.NoError(t, err)
+	require.Equal(t, SQLite, d)
+
+	d, err = DialectForDB("mysql", db)
+	require.NoError(t, err)
+	require.Equal(t, MySQL, d)
+}
+
+func TestDialectForDB_RecognizesPGX(t *testing.T) {
+	// neither opens a connection
+	db, err := sql.Open("pgx", "postgres://u@localhost/app")
+	require.NoError(t, err)
+	defer db.Close()
+	d, err := DialectForDB("", db)
+	require.NoError(t, err)
+	require.Equal(t, PGX, d)
+
+	pool, err := pgxpool.New(context.Background(), "postgres://u@localhost/app")
+	require.NoError(t, err)
+	defer pool.Close()
+	fromPool := stdlib.OpenDBFromPool(pool)
+	defer fromPool.Close()
+	d, err = DialectForDB("", fromPool)
+	require.NoError(t, err)
+	require.Equal(t, PGX, d)
+
+	lib, err := sql.Open("postgres", "host=localhost")
+	require.NoError(t, err)
+	defer lib.Close()
+	d, err = DialectForDB("", lib)
+	require.NoError(t, err)
+	require.Equal(t, Postgres, d)
+}
+
+func TestSQLite_UpgradesOldMetaTable(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dev.db")
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE gomigrator_schema_migrations (
+		version    INTEGER   PRIMARY KEY,
+		name       TEXT      NOT NULL,
+		is_applied BOOLEAN   NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO gomigrator_schema_migrations (version, name, is_applied) VALUES (1, 'init', true)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	s, err := Connect(ctx, "sqlite://"+path, Options{})
+	require.NoError(t, err)
+	defer s.Close()
+
+	recs, err := s.Records(ctx)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	require.Equal(t, "init", recs[0].Name)
+	require.Empty(t, recs[0].Checksum)
+	require.Zero(t, recs[0].DurationMs)
+
+	v, err := s.metaVersion(ctx, s.db)
+	require.NoError(t, err)
+	require.Equal(t, MetaVersion, v)
+
+	// a second Open finds nothing left to upgrade
+	s2, err := Connect(ctx, "sqlite://"+path, Options{})
+	require.NoError(t, err)
+	require.NoError(t, s2.Close())
+}
+
+func TestSQLite_RefusesNewerMetaVersion(t *testing.T) {
+	ctx := context.Background()
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "dev.db")
+	s, err := Connect(ctx, dsn, Options{})
+	require.NoError(t, err)
+	_, err = s.db.Exec(`UPDATE gomigrator_meta_version SET version = version + 1`)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	_, err = Connect(ctx, dsn, Options{})
+	require.ErrorIs(t, err, ErrMetaTooNew)
+}
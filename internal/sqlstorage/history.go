@@ -0,0 +1,61 @@
+package sqlstorage
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// HistoryEvent is a row of the append-only history table. Rows are never
+// updated or deleted, so rolled-back migrations stay visible.
+type HistoryEvent struct {
+	ID          int64     `db:"id"`
+	Version     int64     `db:"version"`
+	Name        string    `db:"name"`
+	Kind        string    `db:"kind"`      // up, down, redo, force, baseline
+	Direction   string    `db:"direction"` // up or down
+	Checksum    string    `db:"checksum"`
+	DurationMs  int64     `db:"duration_ms"`
+	OSUser      string    `db:"os_user"`
+	Hostname    string    `db:"hostname"`
+	ToolVersion string    `db:"tool_version"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// Who runs the migrations; stored with every history event.
+type identity struct {
+	user, host string
+}
+
+func currentIdentity() identity {
+	var who identity
+	if u, err := user.Current(); err == nil {
+		who.user = u.Username
+	}
+	who.host, _ = os.Hostname()
+	return who
+}
+
+// Append ev to the history table inside tx. OS user, hostname and tool
+// version are filled in by the Store; ID and CreatedAt by the database.
+func (s *Store) AppendHistory(ctx context.Context, tx *sqlx.Tx, ev HistoryEvent) error {
+	_, err := tx.ExecContext(ctx, s.dialect.Rebind(`INSERT INTO `+s.historyTable()+`
+		 (version, name, kind, direction, checksum, duration_ms, os_user, hostname, tool_version)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		ev.Version, ev.Name, ev.Kind, ev.Direction, ev.Checksum, ev.DurationMs,
+		s.who.user, s.who.host, s.opts.ToolVersion)
+	return err
+}
+
+// Return every history event, oldest first.
+func (s *Store) History(ctx context.Context) ([]HistoryEvent, error) {
+	var res []HistoryEvent
+	err := sqlx.SelectContext(ctx, s.db, &res,
+		`SELECT id, version, name, kind, direction, checksum, duration_ms,
+		        os_user, hostname, tool_version, created_at
+		 FROM `+s.historyTable()+` ORDER BY id`)
+	return res, err
+}
@@ -0,0 +1,96 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrLockTimeout is returned by WithLock when the advisory lock could not be
+// taken within Options.LockTimeout (or at once with Options.TryLock).
+var ErrLockTimeout = errors.New("timed out waiting for migration lock")
+
+// How often a busy lock is retried when LockTimeout is set.
+var lockPollInterval = 500 * time.Millisecond
+
+// Manage the migration lock. Both calls must use the same session: the lock
+// belongs to the connection that took it.
+func (s *Store) acquireLock(ctx context.Context, conn *sqlx.Conn) error {
+	if s.opts.LockTimeout <= 0 && !s.opts.TryLock {
+		// try first so a caller stuck behind another process learns which
+		if s.opts.OnLockWait != nil {
+			ok, err := s.dialect.TryLock(ctx, conn, s.lockID)
+			if err != nil || ok {
+				return err
+			}
+			s.opts.OnLockWait(s.lockHolder(ctx, conn))
+		}
+		return s.dialect.Lock(ctx, conn, s.lockID)
+	}
+
+	deadline := time.Now().Add(s.opts.LockTimeout)
+	var holder string
+	for attempt := 0; ; attempt++ {
+		ok, err := s.dialect.TryLock(ctx, conn, s.lockID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if attempt == 0 {
+			holder = s.lockHolder(ctx, conn)
+			if s.opts.OnLockWait != nil {
+				s.opts.OnLockWait(holder)
+			}
+		}
+
+		wait := time.Until(deadline)
+		if s.opts.TryLock || wait <= 0 {
+			return fmt.Errorf("%w: held by %s", ErrLockTimeout, holder)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(wait, lockPollInterval)):
+		}
+	}
+}
+
+// Describes the session holding the lock for logs and errors.
+func (s *Store) lockHolder(ctx context.Context, conn *sqlx.Conn) string {
+	if h := s.dialect.LockHolder(ctx, conn, s.lockID); h != "" {
+		return h
+	}
+	return "an unknown session"
+}
+
+// Unlock even if ctx is already cancelled. If that fails too, the connection
+// is discarded instead of going back to the pool, so the server drops the lock
+// together with the session.
+func (s *Store) releaseLock(ctx context.Context, conn *sqlx.Conn) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.dialect.Unlock(ctx, conn, s.lockID); err != nil {
+		_ = conn.Raw(func(dc any) error {
+			// Closing a connection borrowed by stdlib.OpenDBFromPool only
+			// returns it to the pgxpool; a closed pgx.Conn gets destroyed.
+			if pc, ok := dc.(*stdlib.Conn); ok {
+				_ = pc.Conn().Close(ctx)
+			}
+			return driver.ErrBadConn
+		})
+	}
+}
+
+// Take a hash of the key to use as an advisory lock ID.
+func hashLockID(key string) int64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum32())
+}
@@ -0,0 +1,154 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql" // mysql driver
+	"github.com/jmoiron/sqlx"
+)
+
+// MySQL (and MariaDB) locks with named GET_LOCK locks, which like Postgres
+// advisory locks belong to the connection that took them. MySQL commits
+// implicitly around DDL, so a failing migration may leave earlier DDL of
+// its transaction applied.
+var MySQL Dialect = mysqlDialect{}
+
+type mysqlDialect struct{}
+
+// %s is the quoted, possibly database-qualified meta table name.
+const mysqlMetaTableDDL = `
+CREATE TABLE IF NOT EXISTS %s (
+	version      BIGINT       NOT NULL PRIMARY KEY,
+	name         VARCHAR(255) NOT NULL,
+	is_applied   BOOLEAN      NOT NULL,
+	applied_at   TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	checksum     VARCHAR(64)  NOT NULL DEFAULT '',
+	duration_ms  BIGINT       NOT NULL DEFAULT 0,
+	os_user      VARCHAR(255) NOT NULL DEFAULT '',
+	hostname     VARCHAR(255) NOT NULL DEFAULT '',
+	tool_version VARCHAR(64)  NOT NULL DEFAULT ''
+)`
+
+// Definitions of the meta columns added after the first release.
+var mysqlAddedColumns = map[string]string{
+	"checksum":     "VARCHAR(64) NOT NULL DEFAULT ''",
+	"duration_ms":  "BIGINT NOT NULL DEFAULT 0",
+	"os_user":      "VARCHAR(255) NOT NULL DEFAULT ''",
+	"hostname":     "VARCHAR(255) NOT NULL DEFAULT ''",
+	"tool_version": "VARCHAR(64) NOT NULL DEFAULT ''",
+}
+
+func (mysqlDialect) Name() string           { return "mysql" }
+func (mysqlDialect) DriverName() string     { return "mysql" }
+func (mysqlDialect) MaxOpenConns() int      { return 10 }
+func (mysqlDialect) TransactionalDDL() bool { return false }
+
+func (mysqlDialect) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// A MySQL schema is a database; it is created like Postgres schemas are.
+func (d mysqlDialect) MetaDDL(schema, table string) []string {
+	var res []string
+	if schema != "" {
+		res = append(res, "CREATE DATABASE IF NOT EXISTS "+d.QuoteIdent(schema))
+	}
+	return append(res, fmt.Sprintf(mysqlMetaTableDDL, table))
+}
+
+func (mysqlDialect) AddColumnDDL(table, column string) string {
+	return "ALTER TABLE " + table + " ADD COLUMN " + column + " " + mysqlAddedColumns[column]
+}
+
+func (mysqlDialect) HistoryDDL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+	id           BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	version      BIGINT       NOT NULL,
+	name         VARCHAR(255) NOT NULL,
+	kind         VARCHAR(16)  NOT NULL,
+	direction    VARCHAR(8)   NOT NULL,
+	checksum     VARCHAR(64)  NOT NULL DEFAULT '',
+	duration_ms  BIGINT       NOT NULL DEFAULT 0,
+	os_user      VARCHAR(255) NOT NULL DEFAULT '',
+	hostname     VARCHAR(255) NOT NULL DEFAULT '',
+	tool_version VARCHAR(64)  NOT NULL DEFAULT '',
+	created_at   TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+)`
+}
+
+func (mysqlDialect) UpsertApplied(table string) string {
+	return `INSERT INTO ` + table + `
+		 (version, name, is_applied, checksum, duration_ms, os_user, hostname, tool_version)
+		 VALUES (?, ?, true, ?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE
+		 is_applied = true, applied_at = CURRENT_TIMESTAMP(6), checksum = VALUES(checksum),
+		 duration_ms = VALUES(duration_ms), os_user = VALUES(os_user),
+		 hostname = VALUES(hostname), tool_version = VALUES(tool_version)`
+}
+
+func (mysqlDialect) Rebind(query string) string { return query }
+
+// GET_LOCK returns 1 when the lock is taken, 0 on timeout and NULL on error.
+func (d mysqlDialect) Lock(ctx context.Context, conn *sqlx.Conn, id int64) error {
+	ok, err := d.getLock(ctx, conn, id, -1)
+	if err == nil && !ok {
+		err = errors.New("GET_LOCK did not take the migration lock")
+	}
+	return err
+}
+
+func (d mysqlDialect) TryLock(ctx context.Context, conn *sqlx.Conn, id int64) (bool, error) {
+	return d.getLock(ctx, conn, id, 0)
+}
+
+func (mysqlDialect) getLock(ctx context.Context, conn *sqlx.Conn, id int64, timeout int) (bool, error) {
+	var res sql.NullInt64
+	if err := conn.QueryRowxContext(ctx, "SELECT GET_LOCK(?, ?)", mysqlLockName(id), timeout).Scan(&res); err != nil {
+		return false, err
+	}
+	return res.Valid && res.Int64 == 1, nil
+}
+
+func (mysqlDialect) Unlock(ctx context.Context, conn *sqlx.Conn, id int64) error {
+	var res sql.NullInt64
+	err := conn.QueryRowxContext(ctx, "SELECT RELEASE_LOCK(?)", mysqlLockName(id)).Scan(&res)
+	if err == nil && (!res.Valid || res.Int64 != 1) {
+		err = errors.New("RELEASE_LOCK: migration lock was not held by this session")
+	}
+	return err
+}
+
+// Describe the connection holding the lock from information_schema.PROCESSLIST.
+func (mysqlDialect) LockHolder(ctx context.Context, conn *sqlx.Conn, id int64) string {
+	var h struct {
+		ID   int64  `db:"id"`
+		User string `db:"user"`
+		Host string `db:"host"`
+		Time int64  `db:"time"`
+	}
+	err := conn.QueryRowxContext(ctx,
+		`SELECT p.ID AS id, p.USER AS user, p.HOST AS host, p.TIME AS time
+		 FROM information_schema.PROCESSLIST p
+		 WHERE p.ID = IS_USED_LOCK(?)`, mysqlLockName(id)).StructScan(&h)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("connection %d (user %q, host %s, current command running %ds)",
+		h.ID, h.User, h.Host, h.Time)
+}
+
+func mysqlLockName(id int64) string { return fmt.Sprintf("gomigrator:%d", id) }
+
+// Meta rows are scanned into time.Time, which needs parseTime.
+func mysqlDSN(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return dsn // let Connect report it
+	}
+	cfg.ParseTime = true
+	return cfg.FormatDSN()
+}
@@ -0,0 +1,84 @@
+package sqlstorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func newMySQLMockStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	return NewWithDialect(sqlx.NewDb(db, "mysql"), MySQL, 42), mock
+}
+
+func TestMySQL_LocksWithGetLock(t *testing.T) {
+	s, mock := newMySQLMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WithArgs("gomigrator:42", -1).
+		WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `gomigrator_schema_migrations` .* ON DUPLICATE KEY UPDATE").
+		WithArgs(int64(1), "init", "sum", int64(0), sqlmock.AnyArg(), sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM `gomigrator_schema_migrations` WHERE version = \\?").
+		WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT RELEASE_LOCK\(\?\)`).WithArgs("gomigrator:42").
+		WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(1))
+
+	err := s.WithLock(ctx, func(sess *Session) error {
+		return sess.InTx(ctx, func(tx *sqlx.Tx) error {
+			if err := s.MarkApplied(ctx, tx, 1, "init", "sum", 0); err != nil {
+				return err
+			}
+			return s.MarkRolledBack(ctx, tx, 1)
+		})
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMySQL_TryLockReportsHolder(t *testing.T) {
+	s, mock := newMySQLMockStore(t)
+	s.WithOptions(Options{TryLock: true})
+
+	mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WithArgs("gomigrator:42", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(0))
+	mock.ExpectQuery(`information_schema.PROCESSLIST .* IS_USED_LOCK\(\?\)`).WithArgs("gomigrator:42").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user", "host", "time"}).
+			AddRow(int64(9), "deploy", "10.0.0.5:5123", int64(30)))
+
+	err := s.WithLock(context.Background(), func(*Session) error { return nil })
+	require.ErrorIs(t, err, ErrLockTimeout)
+	require.Contains(t, err.Error(), "connection 9")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMySQL_DiscardsSessionWhenLockWasLost(t *testing.T) {
+	s, mock := newMySQLMockStore(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	mock.ExpectQuery(`SELECT GET_LOCK`).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	mock.ExpectQuery(`SELECT RELEASE_LOCK`).WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(nil))
+
+	require.NoError(t, s.WithLock(ctx, func(*Session) error { return nil }))
+	require.Equal(t, 0, s.db.Stats().OpenConnections)
+}
+
+func TestMySQL_QuotesWithBackticks(t *testing.T) {
+	require.Equal(t, "`odd``name`", MySQL.QuoteIdent("odd`name"))
+	ddl := MySQL.MetaDDL("app", "`app`.`migrations`")
+	require.Len(t, ddl, 2)
+	require.Equal(t, "CREATE DATABASE IF NOT EXISTS `app`", ddl[0])
+	require.Contains(t, ddl[1], "CREATE TABLE IF NOT EXISTS `app`.`migrations`")
+}
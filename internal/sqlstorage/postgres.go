@@ -0,0 +1,143 @@
+package sqlstorage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // pgx driver
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq" // postgres driver
+)
+
+// Postgres locks with session-level advisory locks. It uses lib/pq;
+// PGX is the same dialect on top of the pgx driver.
+var (
+	Postgres Dialect = postgres{driver: "postgres"}
+	PGX      Dialect = postgres{driver: "pgx"}
+)
+
+type postgres struct {
+	driver string
+}
+
+// %s is the quoted, possibly schema-qualified meta table name.
+const pgMetaTableDDL = `
+CREATE TABLE IF NOT EXISTS %s (
+	version      BIGINT      PRIMARY KEY,
+	name         TEXT        NOT NULL,
+	is_applied   BOOLEAN     NOT NULL,
+	applied_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	checksum     TEXT        NOT NULL DEFAULT '',
+	duration_ms  BIGINT      NOT NULL DEFAULT 0,
+	os_user      TEXT        NOT NULL DEFAULT '',
+	hostname     TEXT        NOT NULL DEFAULT '',
+	tool_version TEXT        NOT NULL DEFAULT ''
+)`
+
+// Definitions of the meta columns added after the first release.
+var pgAddedColumns = map[string]string{
+	"checksum":     "TEXT NOT NULL DEFAULT ''",
+	"duration_ms":  "BIGINT NOT NULL DEFAULT 0",
+	"os_user":      "TEXT NOT NULL DEFAULT ''",
+	"hostname":     "TEXT NOT NULL DEFAULT ''",
+	"tool_version": "TEXT NOT NULL DEFAULT ''",
+}
+
+func (p postgres) Name() string         { return p.driver }
+func (p postgres) DriverName() string   { return p.driver }
+func (postgres) TransactionalDDL() bool { return true }
+func (postgres) MaxOpenConns() int      { return 10 }
+
+func (postgres) QuoteIdent(name string) string { return pq.QuoteIdentifier(name) }
+
+func (postgres) MetaDDL(schema, table string) []string {
+	var res []string
+	if schema != "" {
+		res = append(res, "CREATE SCHEMA IF NOT EXISTS "+pq.QuoteIdentifier(schema))
+	}
+	return append(res, fmt.Sprintf(pgMetaTableDDL, table))
+}
+
+func (postgres) AddColumnDDL(table, column string) string {
+	return "ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS " + column + " " + pgAddedColumns[column]
+}
+
+func (postgres) HistoryDDL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+	id           BIGSERIAL   PRIMARY KEY,
+	version      BIGINT      NOT NULL,
+	name         TEXT        NOT NULL,
+	kind         TEXT        NOT NULL,
+	direction    TEXT        NOT NULL,
+	checksum     TEXT        NOT NULL DEFAULT '',
+	duration_ms  BIGINT      NOT NULL DEFAULT 0,
+	os_user      TEXT        NOT NULL DEFAULT '',
+	hostname     TEXT        NOT NULL DEFAULT '',
+	tool_version TEXT        NOT NULL DEFAULT '',
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+}
+
+func (postgres) UpsertApplied(table string) string {
+	return `INSERT INTO ` + table + `
+		 (version, name, is_applied, checksum, duration_ms, os_user, hostname, tool_version)
+		 VALUES ($1, $2, true, $3, $4, $5, $6, $7)
+		 ON CONFLICT (version) DO UPDATE
+		 SET is_applied = true, applied_at = now(), checksum = EXCLUDED.checksum,
+		     duration_ms = EXCLUDED.duration_ms, os_user = EXCLUDED.os_user,
+		     hostname = EXCLUDED.hostname, tool_version = EXCLUDED.tool_version`
+}
+
+func (postgres) Rebind(query string) string { return sqlx.Rebind(sqlx.DOLLAR, query) }
+
+func (postgres) Lock(ctx context.Context, conn *sqlx.Conn, id int64) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", id)
+	return err
+}
+
+func (postgres) TryLock(ctx context.Context, conn *sqlx.Conn, id int64) (bool, error) {
+	var ok bool
+	err := conn.QueryRowxContext(ctx, "SELECT pg_try_advisory_lock($1)", id).Scan(&ok)
+	return ok, err
+}
+
+func (postgres) Unlock(ctx context.Context, conn *sqlx.Conn, id int64) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", id)
+	return err
+}
+
+// Describe the session holding the advisory lock, as far as
+// pg_locks/pg_stat_activity tell (which may need pg_read_all_stats).
+func (postgres) LockHolder(ctx context.Context, conn *sqlx.Conn, id int64) string {
+	var h struct {
+		PID     int64     `db:"pid"`
+		User    string    `db:"usename"`
+		App     string    `db:"application_name"`
+		Client  string    `db:"client"`
+		Started time.Time `db:"backend_start"`
+	}
+	err := conn.QueryRowxContext(ctx,
+		`SELECT a.pid, coalesce(a.usename, '') AS usename,
+		        coalesce(a.application_name, '') AS application_name,
+		        coalesce(host(a.client_addr), 'local') AS client, a.backend_start
+		 FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+		 WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1
+		   AND l.classid = ($1::bigint >> 32)::oid
+		   AND l.objid = ($1::bigint & 4294967295)::oid
+		 LIMIT 1`, id).StructScan(&h)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("pid %d (user %q, application %q, client %s, connected since %s)",
+		h.PID, h.User, h.App, h.Client, h.Started.Format(time.RFC3339))
+}
+
+// pgx takes postgres:// URLs and key=value strings; pgx:// is ours.
+func pgxDSN(dsn string) string {
+	if rest, ok := strings.CutPrefix(dsn, "pgx://"); ok {
+		return "postgres://" + rest
+	}
+	return dsn
+}
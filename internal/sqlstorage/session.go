@@ -0,0 +1,44 @@
+package sqlstorage
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Session is the single connection that holds the advisory lock during
+// Store.WithLock. Reads, transactions and non-transactional statements made
+// under the lock all go through it, so they run in the lock-owning session.
+type Session struct {
+	conn  *sqlx.Conn
+	table string // quoted meta table name
+}
+
+// Start transaction on the session, call fn and commit; roll back if fn fails.
+func (s *Session) InTx(ctx context.Context, fn func(*sqlx.Tx) error) error {
+	tx, err := s.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Run query on the session outside of any transaction.
+func (s *Session) Exec(ctx context.Context, query string) error {
+	_, err := s.conn.ExecContext(ctx, query)
+	return err
+}
+
+// Return map[version]isApplied.
+func (s *Session) AppliedVersions(ctx context.Context) (map[int64]bool, error) {
+	return appliedVersions(ctx, s.conn, s.table)
+}
+
+// Return every meta table row sorted by version.
+func (s *Session) Records(ctx context.Context) ([]Record, error) {
+	return records(ctx, s.conn, s.table)
+}
@@ -0,0 +1,94 @@
+package sqlstorage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3" // sqlite driver
+)
+
+// SQLite is meant for local development and tests against a file database.
+// The pool is limited to the one connection WithLock pins, which also keeps
+// ":memory:" databases usable and makes WithLock calls on one Store wait
+// for each other. SQLite has no session locks, so Lock is a no-op: two
+// Stores or processes migrating the same file are not serialized and only
+// SQLite's own write locking keeps them from writing at the same time.
+var SQLite Dialect = sqlite{}
+
+type sqlite struct{}
+
+const sqliteMetaTableDDL = `
+CREATE TABLE IF NOT EXISTS %s (
+	version      INTEGER   PRIMARY KEY,
+	name         TEXT      NOT NULL,
+	is_applied   BOOLEAN   NOT NULL,
+	applied_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	checksum     TEXT      NOT NULL DEFAULT '',
+	duration_ms  INTEGER   NOT NULL DEFAULT 0,
+	os_user      TEXT      NOT NULL DEFAULT '',
+	hostname     TEXT      NOT NULL DEFAULT '',
+	tool_version TEXT      NOT NULL DEFAULT ''
+)`
+
+// Definitions of the meta columns added after the first release.
+var sqliteAddedColumns = map[string]string{
+	"checksum":     "TEXT NOT NULL DEFAULT ''",
+	"duration_ms":  "INTEGER NOT NULL DEFAULT 0",
+	"os_user":      "TEXT NOT NULL DEFAULT ''",
+	"hostname":     "TEXT NOT NULL DEFAULT ''",
+	"tool_version": "TEXT NOT NULL DEFAULT ''",
+}
+
+func (sqlite) Name() string           { return "sqlite" }
+func (sqlite) DriverName() string     { return "sqlite3" }
+func (sqlite) TransactionalDDL() bool { return true }
+func (sqlite) MaxOpenConns() int      { return 1 }
+
+// Same rules as Postgres: double quotes, embedded quotes doubled.
+func (sqlite) QuoteIdent(name string) string { return Postgres.QuoteIdent(name) }
+
+// schema can only name an attached database, which must already exist.
+func (sqlite) MetaDDL(_, table string) []string {
+	return []string{fmt.Sprintf(sqliteMetaTableDDL, table)}
+}
+
+func (sqlite) AddColumnDDL(table, column string) string {
+	return "ALTER TABLE " + table + " ADD COLUMN " + column + " " + sqliteAddedColumns[column]
+}
+
+func (sqlite) HistoryDDL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+	id           INTEGER   PRIMARY KEY AUTOINCREMENT,
+	version      INTEGER   NOT NULL,
+	name         TEXT      NOT NULL,
+	kind         TEXT      NOT NULL,
+	direction    TEXT      NOT NULL,
+	checksum     TEXT      NOT NULL DEFAULT '',
+	duration_ms  INTEGER   NOT NULL DEFAULT 0,
+	os_user      TEXT      NOT NULL DEFAULT '',
+	hostname     TEXT      NOT NULL DEFAULT '',
+	tool_version TEXT      NOT NULL DEFAULT '',
+	created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+}
+
+func (sqlite) UpsertApplied(table string) string {
+	return `INSERT INTO ` + table + `
+		 (version, name, is_applied, checksum, duration_ms, os_user, hostname, tool_version)
+		 VALUES (?, ?, true, ?, ?, ?, ?, ?)
+		 ON CONFLICT (version) DO UPDATE
+		 SET is_applied = true, applied_at = CURRENT_TIMESTAMP, checksum = excluded.checksum,
+		     duration_ms = excluded.duration_ms, os_user = excluded.os_user,
+		     hostname = excluded.hostname, tool_version = excluded.tool_version`
+}
+
+func (sqlite) Rebind(query string) string { return query }
+
+func (sqlite) Lock(context.Context, *sqlx.Conn, int64) error { return nil }
+
+func (sqlite) TryLock(context.Context, *sqlx.Conn, int64) (bool, error) { return true, nil }
+
+func (sqlite) Unlock(context.Context, *sqlx.Conn, int64) error { return nil }
+
+func (sqlite) LockHolder(context.Context, *sqlx.Conn, int64) string { return "" }
@@ -1,77 +1,194 @@
 package sqlstorage
 
 import (
+	"cmp"
 	"context"
-	"hash/fnv"
+	"database/sql"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq" // postgres driver
 )
 
-const metaTableDDL = `
-CREATE TABLE IF NOT EXISTS gomigrator_schema_migrations (
-	version     BIGINT      PRIMARY KEY,
-	name        TEXT        NOT NULL,
-	is_applied  BOOLEAN     NOT NULL,
-	applied_at  TIMESTAMPTZ NOT NULL DEFAULT now()
-);`
+// Defaults used when Options leave the names empty.
+const (
+	DefaultTable        = "gomigrator_schema_migrations"
+	DefaultHistoryTable = "gomigrator_history"
+	DefaultLockKey      = "gomigrator"
+)
+
+// Record is a single row of the meta table.
+type Record struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	IsApplied bool      `db:"is_applied"`
+	AppliedAt time.Time `db:"applied_at"`
+	Checksum  string    `db:"checksum"` // empty for rows written before checksums existed
+
+	// Zero for rows written before these columns existed, and for
+	// versions marked by force or baseline without running them.
+	DurationMs  int64  `db:"duration_ms"`
+	OSUser      string `db:"os_user"`
+	Hostname    string `db:"hostname"`
+	ToolVersion string `db:"tool_version"`
+}
 
 type Store struct {
-	db     *sqlx.DB
-	lockID int64 // pg_advisory_lock(key)
+	db      *sqlx.DB
+	dialect Dialect
+	lockID  int64 // hash of the lock key
+	opts    Options
+	shared  bool // db belongs to the caller; Close leaves it open
+	who     identity
+}
+
+// Options tune the Store. The zero value keeps the defaults.
+type Options struct {
+	// Driver picks the Dialect by name ("postgres", "pgx", "mysql",
+	// "sqlite"); empty means it is guessed from the DSN scheme,
+	// falling back to Postgres.
+	Driver string
+
+	// LockTimeout bounds how long WithLock waits for the advisory lock;
+	// zero waits forever. On expiry WithLock fails with ErrLockTimeout.
+	LockTimeout time.Duration
+	// TryLock makes WithLock fail with ErrLockTimeout right away
+	// if another session holds the lock.
+	TryLock bool
+	// OnLockWait, if set, is called once when the lock is busy,
+	// with a description of the session holding it.
+	OnLockWait func(holder string)
+
+	// Table and Schema name the meta table; empty Table means DefaultTable
+	// and empty Schema means the first schema on the search_path.
+	Table  string
+	Schema string
+	// LockKey is hashed into the advisory lock ID; empty means DefaultLockKey.
+	// Apps sharing a database should use different keys and tables.
+	LockKey string
+
+	// ToolVersion is stored with every applied migration and history event.
+	ToolVersion string
 }
 
 // NewWithMock is only for tests; allows injection of custom DB.
+// The Store speaks the Postgres dialect.
 func NewWithMock(db *sqlx.DB, lockID int64) *Store {
+	return NewWithDialect(db, Postgres, lockID)
+}
+
+// NewWithDialect wraps an opened DB that speaks dialect; like NewWithMock
+// it does not create the meta table.
+func NewWithDialect(db *sqlx.DB, dialect Dialect, lockID int64) *Store {
 	return &Store{
-		db:     db,
-		lockID: lockID,
+		db:      db,
+		dialect: dialect,
+		lockID:  lockID,
+		who:     currentIdentity(),
 	}
 }
 
-func Connect(ctx context.Context, dsn string) (*Store, error) {
-	db, err := sqlx.ConnectContext(ctx, "postgres", dsn)
+// Sets options and returns the same Store for chaining.
+func (s *Store) WithOptions(opts Options) *Store {
+	s.opts = opts
+	return s
+}
+
+func Connect(ctx context.Context, dsn string, opts Options) (*Store, error) {
+	dialect, dsn, err := DialectFor(opts.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sqlx.ConnectContext(ctx, dialect.DriverName(), dsn)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxOpenConns(10)
+	db.SetMaxOpenConns(dialect.MaxOpenConns())
 	db.SetConnMaxLifetime(time.Hour)
 
-	s := &Store{
-		db:     db,
-		lockID: hashLockID("gomigrator"),
+	s, err := Open(ctx, db, dialect, opts)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewWithDB wraps the caller's pool and creates the meta table. Unlike Connect,
+// the pool's limits are left alone and Close does not close it.
+func NewWithDB(ctx context.Context, db *sql.DB, dialect Dialect, opts Options) (*Store, error) {
+	s, err := Open(ctx, sqlx.NewDb(db, dialect.DriverName()), dialect, opts)
+	if err != nil {
+		return nil, err
 	}
+	s.shared = true
+	return s, nil
+}
+
+// Open wraps an opened DB that speaks dialect and creates the meta table;
+// opts.Driver is ignored. The Store owns db: Close closes it.
+func Open(ctx context.Context, db *sqlx.DB, dialect Dialect, opts Options) (*Store, error) {
+	s := NewWithDialect(db, dialect, hashLockID(cmp.Or(opts.LockKey, DefaultLockKey))).WithOptions(opts)
 	if err := s.ensureMetaTable(ctx); err != nil {
-		_ = db.Close()
 		return nil, err
 	}
 	return s, nil
 }
-func (s *Store) Close() error { return s.db.Close() }
 
-// Take advisory-lock, start transaction, call fn and commit.
-func (s *Store) WithExclusive(ctx context.Context, fn func(*sqlx.Tx) error) error {
-	if err := s.acquireLock(ctx); err != nil {
-		return err
+func (s *Store) Close() error {
+	if s.shared {
+		return nil
 	}
-	defer s.releaseLock(ctx)
+	return s.db.Close()
+}
+
+// Returns the quoted, schema-qualified (if set) name of the meta table.
+func (s *Store) table() string {
+	return s.qualify(cmp.Or(s.opts.Table, DefaultTable))
+}
 
-	tx, err := s.db.BeginTxx(ctx, nil)
+// Returns the quoted, schema-qualified (if set) name of the history table:
+// DefaultHistoryTable, or <Table>_history when the meta table is renamed.
+func (s *Store) historyTable() string {
+	if s.opts.Table == "" {
+		return s.qualify(DefaultHistoryTable)
+	}
+	return s.qualify(s.opts.Table + "_history")
+}
+
+func (s *Store) qualify(name string) string {
+	name = s.dialect.QuoteIdent(name)
+	if s.opts.Schema != "" {
+		name = s.dialect.QuoteIdent(s.opts.Schema) + "." + name
+	}
+	return name
+}
+
+// Pin one connection, take advisory-lock on it, call fn and release the lock
+// on that same connection. Everything fn does under the lock must go through
+// sess so it runs in the session that owns the lock.
+func (s *Store) WithLock(ctx context.Context, fn func(sess *Session) error) error {
+	conn, err := s.db.Connx(ctx)
 	if err != nil {
 		return err
 	}
-	if err := fn(tx); err != nil {
-		_ = tx.Rollback()
+	defer conn.Close()
+
+	if err := s.acquireLock(ctx, conn); err != nil {
 		return err
 	}
-	return tx.Commit()
+	defer s.releaseLock(ctx, conn)
+
+	return fn(&Session{conn: conn, table: s.table()})
 }
 
 // Return map[version]isApplied.
 func (s *Store) AppliedVersions(ctx context.Context) (map[int64]bool, error) {
-	rows, err := s.db.QueryxContext(ctx,
-		`SELECT version, is_applied FROM gomigrator_schema_migrations`)
+	return appliedVersions(ctx, s.db, s.table())
+}
+
+func appliedVersions(ctx context.Context, q sqlx.QueryerContext, table string) (map[int64]bool, error) {
+	rows, err := q.QueryxContext(ctx,
+		`SELECT version, is_applied FROM `+table)
 	if err != nil {
 		return nil, err
 	}
@@ -89,42 +206,43 @@ func (s *Store) AppliedVersions(ctx context.Context) (map[int64]bool, error) {
 	return res, rows.Err()
 }
 
-// Add migration record.
-func (s *Store) MarkApplied(ctx context.Context, tx *sqlx.Tx, version int64, name string) error {
-	_, err := tx.ExecContext(ctx,
-		`INSERT INTO gomigrator_schema_migrations (version, name, is_applied)
-		 VALUES ($1, $2, true)
-		 ON CONFLICT (version) DO UPDATE SET is_applied = true, applied_at = now()`,
-		version, name)
-	return err
+// Return every meta table row sorted by version.
+func (s *Store) Records(ctx context.Context) ([]Record, error) {
+	return records(ctx, s.db, s.table())
 }
 
-// Remove migration record.
-func (s *Store) MarkRolledBack(ctx context.Context, tx *sqlx.Tx, version int64) error {
-	_, err := tx.ExecContext(ctx,
-		`DELETE FROM gomigrator_schema_migrations WHERE version = $1`, version)
-	return err
+func records(ctx context.Context, q sqlx.QueryerContext, table string) ([]Record, error) {
+	var res []Record
+	err := sqlx.SelectContext(ctx, q, &res,
+		`SELECT version, name, is_applied, applied_at, checksum,
+		        duration_ms, os_user, hostname, tool_version FROM `+table+`
+		 ORDER BY version`)
+	return res, err
 }
 
-// Create meta table if not exists.
-func (s *Store) ensureMetaTable(ctx context.Context) error {
-	_, err := s.db.ExecContext(ctx, metaTableDDL)
+// Add migration record together with the checksum of its file, how long
+// it took and who applied it.
+func (s *Store) MarkApplied(ctx context.Context, tx *sqlx.Tx, version int64, name, checksum string,
+	took time.Duration,
+) error {
+	_, err := tx.ExecContext(ctx, s.dialect.UpsertApplied(s.table()),
+		version, name, checksum, took.Milliseconds(), s.who.user, s.who.host, s.opts.ToolVersion)
 	return err
 }
 
-// Manage advisory locks.
-func (s *Store) acquireLock(ctx context.Context) error {
-	_, err := s.db.ExecContext(ctx, "SELECT pg_advisory_lock($1)", s.lockID)
+// Remove migration record.
+func (s *Store) MarkRolledBack(ctx context.Context, tx *sqlx.Tx, version int64) error {
+	_, err := tx.ExecContext(ctx,
+		s.dialect.Rebind(`DELETE FROM `+s.table()+` WHERE version = ?`), version)
 	return err
 }
 
-func (s *Store) releaseLock(ctx context.Context) {
-	_, _ = s.db.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", s.lockID)
+// Overwrite the stored checksum of a migration record.
+func (s *Store) UpdateChecksum(ctx context.Context, tx *sqlx.Tx, version int64, checksum string) error {
+	_, err := tx.ExecContext(ctx,
+		s.dialect.Rebind(`UPDATE `+s.table()+` SET checksum = ? WHERE version = ?`), checksum, version)
+	return err
 }
 
-// Take a hash of the key to use as an advisory lock ID.
-func hashLockID(key string) int64 {
-	h := fnv.New32a()
-	_, _ = h.Write([]byte(key))
-	return int64(h.Sum32())
-}
+// Returns the dialect the Store speaks.
+func (s *Store) Dialect() Dialect { return s.dialect }
@@ -0,0 +1,194 @@
+package sqlstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockStore returns a Store whose pool holds at most one connection:
+// any statement sent outside the pinned session would block until ctx expires.
+func newMockStore(t *testing.T) (*Store, *sqlx.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	dbx := sqlx.NewDb(db, "gomigrator")
+	return NewWithMock(dbx, 42), dbx, mock
+}
+
+func TestWithLock_RunsEverythingOnOneSession(t *testing.T) {
+	s, _, mock := newMockStore(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, is_applied FROM \"gomigrator_schema_migrations\"").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "is_applied"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO \"gomigrator_schema_migrations\"").
+		WithArgs(int64(1), "init", "sum", int64(0), sqlmock.AnyArg(), sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("CREATE INDEX CONCURRENTLY").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := s.WithLock(ctx, func(sess *Session) error {
+		if _, err := sess.AppliedVersions(ctx); err != nil {
+			return err
+		}
+		if err := sess.InTx(ctx, func(tx *sqlx.Tx) error {
+			return s.MarkApplied(ctx, tx, 1, "init", "sum", 0)
+		}); err != nil {
+			return err
+		}
+		return sess.Exec(ctx, "CREATE INDEX CONCURRENTLY idx ON t(id)")
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithLock_ReleasesLockWhenFnFails(t *testing.T) {
+	s, _, mock := newMockStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	boom := errors.New("boom")
+	err := s.WithLock(ctx, func(*Session) error {
+		cancel() // the unlock must still go through
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithLock_DiscardsSessionWhenUnlockFails(t *testing.T) {
+	s, db, mock := newMockStore(t)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnError(errors.New("connection reset"))
+
+	require.NoError(t, s.WithLock(context.Background(), func(*Session) error { return nil }))
+	require.NoError(t, mock.ExpectationsWereMet())
+	// the connection still holding the lock must not return to the pool
+	require.Equal(t, 0, db.Stats().OpenConnections)
+}
+
+func TestWithLock_TimesOutWhenLockIsBusy(t *testing.T) {
+	s, _, mock := newMockStore(t)
+	lockPollInterval = 10 * time.Millisecond
+	defer func() { lockPollInterval = 500 * time.Millisecond }()
+
+	var holder string
+	s.WithOptions(Options{
+		LockTimeout: 30 * time.Millisecond,
+		OnLockWait:  func(h string) { holder = h },
+	})
+
+	busy := func() {
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(int64(42)).
+			WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(false))
+	}
+	busy()
+	mock.ExpectQuery("FROM pg_locks").WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"pid", "usename", "application_name", "client", "backend_start"}).
+			AddRow(int64(7), "deploy", "gomigrator", "10.0.0.5", time.Unix(0, 0).UTC()))
+	for range 10 {
+		busy()
+	}
+
+	err := s.WithLock(context.Background(), func(*Session) error {
+		t.Fatal("fn must not run without the lock")
+		return nil
+	})
+	require.ErrorIs(t, err, ErrLockTimeout)
+	require.Contains(t, err.Error(), "pid 7")
+	require.Contains(t, holder, `user "deploy"`)
+}
+
+func TestWithLock_ReportsHolderBeforeBlocking(t *testing.T) {
+	s, _, mock := newMockStore(t)
+	var holder string
+	s.WithOptions(Options{OnLockWait: func(h string) { holder = h }})
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(false))
+	mock.ExpectQuery("FROM pg_locks").WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"pid", "usename", "application_name", "client", "backend_start"}).
+			AddRow(int64(7), "deploy", "gomigrator", "10.0.0.5", time.Unix(0, 0).UTC()))
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, s.WithLock(context.Background(), func(*Session) error { return nil }))
+	require.Contains(t, holder, "pid 7")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithLock_TryLockTakesFreeLock(t *testing.T) {
+	s, _, mock := newMockStore(t)
+	s.WithOptions(Options{TryLock: true})
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(true))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, s.WithLock(context.Background(), func(*Session) error { return nil }))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStore_QuotesConfiguredTable(t *testing.T) {
+	s, _, mock := newMockStore(t)
+	s.WithOptions(Options{Schema: "billing", Table: `odd "name"`})
+
+	mock.ExpectQuery(`SELECT version, is_applied FROM "billing"\."odd ""name"""`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "is_applied"}).AddRow(int64(1), true))
+
+	applied, err := s.AppliedVersions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[int64]bool{1: true}, applied)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHashLockID_DependsOnKey(t *testing.T) {
+	require.Equal(t, hashLockID(DefaultLockKey), hashLockID("gomigrator"))
+	require.NotEqual(t, hashLockID("billing"), hashLockID("gomigrator"))
+}
+
+func TestNewWithDB_LeavesCallerPoolOpen(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	db.SetMaxOpenConns(3)
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "gomigrator_schema_migrations"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "gomigrator_meta_version"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version FROM "gomigrator_meta_version"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(MetaVersion))
+	s, err := NewWithDB(context.Background(), db, Postgres, Options{})
+	require.NoError(t, err)
+	require.Equal(t, 3, db.Stats().MaxOpenConnections)
+
+	require.NoError(t, s.Close())
+	mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err, "caller's pool must stay open")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
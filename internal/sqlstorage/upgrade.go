@@ -0,0 +1,146 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultMetaVersionTable keeps the version of gomigrator's own tables.
+const DefaultMetaVersionTable = "gomigrator_meta_version"
+
+// metaUpgrade brings gomigrator's own tables from version-1 to version.
+// Steps must be idempotent: a step may run again if stamping its version
+// failed, and tables created by MetaDDL already have the latest columns.
+type metaUpgrade struct {
+	version int
+	desc    string
+	apply   func(ctx context.Context, s *Store, conn *sqlx.Conn) error
+}
+
+// Ordered upgrade steps; append new ones, never edit or reorder released ones.
+var metaUpgrades = []metaUpgrade{
+	{1, "add checksum column", func(ctx context.Context, s *Store, conn *sqlx.Conn) error {
+		return s.addMissingColumns(ctx, conn, "checksum")
+	}},
+	{2, "add duration and applied-by columns", func(ctx context.Context, s *Store, conn *sqlx.Conn) error {
+		return s.addMissingColumns(ctx, conn, "duration_ms", "os_user", "hostname", "tool_version")
+	}},
+	{3, "create history table", func(ctx context.Context, s *Store, conn *sqlx.Conn) error {
+		_, err := conn.ExecContext(ctx, s.dialect.HistoryDDL(s.historyTable()))
+		return err
+	}},
+}
+
+// MetaVersion is the version of gomigrator's own tables this release writes.
+var MetaVersion = metaUpgrades[len(metaUpgrades)-1].version
+
+// ErrMetaTooNew means the database was upgraded by a newer gomigrator.
+var ErrMetaTooNew = errors.New("meta tables were written by a newer gomigrator")
+
+// Works on every supported dialect as is.
+const metaVersionDDL = `CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL)`
+
+// Returns the quoted, schema-qualified (if set) name of the table keeping
+// the meta schema version: DefaultMetaVersionTable, or <Table>_meta_version.
+func (s *Store) metaVersionTable() string {
+	if s.opts.Table == "" {
+		return s.qualify(DefaultMetaVersionTable)
+	}
+	return s.qualify(s.opts.Table + "_meta_version")
+}
+
+// Create meta and version tables (and their schema, if set) if not exists,
+// then run the upgrade steps the database has not seen yet. Upgrades run
+// under the migration lock so two releases rolling out at once do not race.
+func (s *Store) ensureMetaTable(ctx context.Context) error {
+	ddl := append(s.dialect.MetaDDL(s.opts.Schema, s.table()),
+		fmt.Sprintf(metaVersionDDL, s.metaVersionTable()))
+	for _, stmt := range ddl {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	current, err := s.metaVersion(ctx, s.db)
+	if err != nil || current == MetaVersion {
+		return err
+	}
+	if current > MetaVersion {
+		return fmt.Errorf("%w: version %d, this release knows up to %d", ErrMetaTooNew, current, MetaVersion)
+	}
+	return s.WithLock(ctx, func(sess *Session) error {
+		return s.upgradeMeta(ctx, sess.conn)
+	})
+}
+
+// Runs the pending upgrade steps on conn, stamping each one as it succeeds.
+func (s *Store) upgradeMeta(ctx context.Context, conn *sqlx.Conn) error {
+	// another process may have upgraded while we waited for the lock
+	current, err := s.metaVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	for _, up := range metaUpgrades {
+		if up.version <= current {
+			continue
+		}
+		if err := up.apply(ctx, s, conn); err != nil {
+			return fmt.Errorf("upgrade meta tables to version %d (%s): %w", up.version, up.desc, err)
+		}
+		if err := s.setMetaVersion(ctx, conn, up.version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the stored meta schema version; 0 if none was stored yet.
+func (s *Store) metaVersion(ctx context.Context, q sqlx.QueryerContext) (int, error) {
+	var v int
+	err := q.QueryRowxContext(ctx, `SELECT version FROM `+s.metaVersionTable()).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return v, err
+}
+
+func (s *Store) setMetaVersion(ctx context.Context, conn *sqlx.Conn, v int) error {
+	if _, err := conn.ExecContext(ctx, `DELETE FROM `+s.metaVersionTable()); err != nil {
+		return err
+	}
+	_, err := conn.ExecContext(ctx,
+		s.dialect.Rebind(`INSERT INTO `+s.metaVersionTable()+` (version) VALUES (?)`), v)
+	return err
+}
+
+// Adds the named columns to a meta table that predates them.
+func (s *Store) addMissingColumns(ctx context.Context, conn *sqlx.Conn, columns ...string) error {
+	rows, err := conn.QueryContext(ctx, `SELECT * FROM `+s.table()+` WHERE 1 = 0`)
+	if err != nil {
+		return err
+	}
+	have, err := rows.Columns()
+	_ = rows.Close()
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool, len(have))
+	for _, c := range have {
+		found[strings.ToLower(c)] = true
+	}
+	for _, c := range columns {
+		if found[c] {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, s.dialect.AddColumnDDL(s.table(), c)); err != nil {
+			return fmt.Errorf("add column %s: %w", c, err)
+		}
+	}
+	return nil
+}
@@ -2,21 +2,241 @@ package gomigrator
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
 
 	core "github.com/hilltracer/gomigrator/internal/migrator"
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
 )
 
 // Describes the minimum set of parameters necessary for connecting
 // to the base and the operation of the migrator.
 type Config struct {
-	DSN string // Postgres connection line
-	Dir string // Dir with SQL migration files
+	DSN string // Postgres connection line, mysql://… or sqlite://path/to/file.db
+	Dir string // Dir with SQL migration files (inside FS when FS is set)
+
+	// Database engine: "postgres" (lib/pq), "pgx", "mysql" or "sqlite".
+	// Empty means it is guessed from the DSN scheme (pgx://, mysql://,
+	// sqlite://, sqlite:), falling back to "postgres". MySQL DSNs use
+	// the go-sql-driver/mysql format.
+	Driver string
+
+	// Optional source of migration files, e.g. an embed.FS:
+	//
+	//	//go:embed migrations/*.sql
+	//	var migrations embed.FS
+	//	cfg := gomigrator.Config{DSN: dsn, FS: migrations, Dir: "migrations"}
+	//
+	// When nil, files are read from Dir on the local disk.
+	FS fs.FS
+
+	// Execute mutating commands in one transaction and roll it back
+	// instead of committing. NoTransaction migrations cannot be dry-run.
+	DryRun bool
+
+	// Refuse to apply migrations while an applied file differs
+	// from the checksum recorded in the DB (see Validate).
+	FailOnDrift bool
+
+	// Apply pending migrations older than the newest applied one
+	// (e.g. after merging branches) in version order, reporting them
+	// through OnWarning, instead of failing with ErrMissingMigrations.
+	AllowMissing bool
+
+	// Let Repair remove every record when none of them has a migration
+	// file, instead of failing with ErrRepairRemovesAll.
+	AllowRemoveAll bool
+
+	// Transaction strategy for commands that apply or roll back several
+	// migrations; empty means TxModeSingle.
+	TxMode TxMode
+
+	// How long to wait for the migration lock held by another process;
+	// zero waits forever. On expiry commands fail with ErrLockTimeout.
+	LockTimeout time.Duration
+
+	// Fail with ErrLockTimeout at once if the lock is busy.
+	TryLock bool
+
+	// Called once when the lock is busy, with a description
+	// of the session holding it (pid, user, application, client).
+	OnLockWait func(holder string)
+
+	// Receives warnings about a run, e.g. that MySQL commits DDL
+	// implicitly so transactions do not make migrations atomic.
+	OnWarning func(msg string)
+
+	// Name of the meta table and its Postgres schema; empty values mean
+	// gomigrator_schema_migrations on the search_path.
+	Table  string
+	Schema string
+
+	// Stored with every history event, e.g. the version of the binary
+	// embedding gomigrator.
+	ToolVersion string
+
+	// Key the advisory lock is derived from; empty means "gomigrator".
+	// Independent apps sharing a database should set their own
+	// LockKey and Table so they neither block nor see each other.
+	LockKey string
+}
+
+// Describes how migrations are grouped into transactions.
+type TxMode string
+
+const (
+	TxModeSingle       TxMode = "single"        // one transaction for the whole run
+	TxModePerMigration TxMode = "per-migration" // commit after each migration
+)
+
+// Returned when a command fails after some migrations were already
+// committed (e.g. with TxModePerMigration); Committed lists them.
+type PartialError struct {
+	Committed []Step
+	Err       error
+}
+
+func (e *PartialError) Error() string {
+	names := make([]string, len(e.Committed))
+	for i, s := range e.Committed {
+		names[i] = fmt.Sprintf("%s %d_%s", s.Direction, s.Version, s.Name)
+	}
+	return fmt.Sprintf("%v (committed before failure: %s)", e.Err, strings.Join(names, ", "))
 }
 
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// Returned by Repair and RepairPlan when no record has a migration file
+// left, unless Config.AllowRemoveAll is set.
+var ErrRepairRemovesAll = core.ErrRepairRemovesAll
+
+// Returned by Up, UpTo and UpByOne when Config.FailOnDrift is set
+// and Validate would report drift.
+var ErrChecksumDrift = core.ErrChecksumDrift
+
+// Returned by Up, UpTo and UpByOne when a pending migration is older than
+// the newest applied one and Config.AllowMissing is not set.
+var ErrMissingMigrations = core.ErrMissingMigrations
+
+// Returned when the migration lock could not be taken within
+// Config.LockTimeout, or at once with Config.TryLock.
+var ErrLockTimeout = sqlstorage.ErrLockTimeout
+
+// Returned by the constructors when a newer gomigrator has already upgraded
+// its own tables in this database.
+var ErrMetaTooNew = sqlstorage.ErrMetaTooNew
+
+// Describes where a migration stands relative to the database.
+type State string
+
+const (
+	StateApplied     State = "applied"      // file exists and is recorded as applied
+	StatePending     State = "pending"      // file exists but was never applied
+	StateMissingFile State = "missing-file" // recorded in the DB but the file is gone
+	StateDrifted     State = "drifted"      // applied, but the file changed since (see Validate)
+)
+
 // Describes the status of a migration.
 type StatusEntry struct {
 	Version   int64
+	Name      string
+	Path      string // empty when the file is missing or for Go migrations
+	State     State
 	IsApplied bool
+	AppliedAt time.Time // zero unless recorded in the DB
+
+	// What the DB recorded about the run. HasRunInfo is false for rows
+	// written by older releases, which recorded none of it; versions marked
+	// by Force or Baseline record a zero Duration.
+	HasRunInfo  bool
+	Duration    time.Duration
+	AppliedBy   string // user@host
+	ToolVersion string
+}
+
+// Direction in which a migration was executed.
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// Describes one migration executed by a command.
+type Step struct {
+	Version   int64
+	Name      string
+	Direction Direction
+}
+
+// Describes an applied migration whose file changed after it was applied.
+type Drift struct {
+	Version  int64
+	Name     string
+	Path     string
+	Recorded string // checksum stored in the DB
+	Actual   string // checksum of the file on disk
+}
+
+// Names the command that produced a history event.
+type EventKind string
+
+const (
+	EventUp       EventKind = "up"
+	EventDown     EventKind = "down"
+	EventRedo     EventKind = "redo"
+	EventForce    EventKind = "force"
+	EventBaseline EventKind = "baseline"
+	EventRepair   EventKind = "repair"
+)
+
+// Describes one event of the append-only migration history.
+type HistoryEntry struct {
+	ID          int64
+	Version     int64
+	Name        string
+	Kind        EventKind
+	Direction   Direction
+	Checksum    string        // of the file at the time; empty if there was none
+	Duration    time.Duration // time spent running the migration's SQL
+	OSUser      string
+	Hostname    string
+	ToolVersion string
+	At          time.Time
+}
+
+// Lists what Repair changes in the meta table.
+type RepairReport struct {
+	Removed []StatusEntry // records whose files no longer exist
+	Updated []Drift       // applied records whose checksum is rewritten to Actual
+}
+
+// Names a mutating command that Plan can preview.
+type Command string
+
+const (
+	CommandUp     Command = "up"
+	CommandUpTo   Command = "up-to"
+	CommandDown   Command = "down"
+	CommandDownTo Command = "down-to"
+	CommandRedo   Command = "redo"
+)
+
+// Describes one migration a previewed command would execute.
+type PlanStep struct {
+	Version       int64
+	Name          string
+	Path          string
+	Direction     Direction
+	NoTransaction bool
+	SQL           string // full Up or Down block; a placeholder for Go migrations
 }
 
 // Allows to use, roll back and check migrations.
@@ -26,24 +246,138 @@ type Migrator struct{ m *core.Migrator }
 
 // Open connection to the database and return a Migrator instance.
 func New(ctx context.Context, cfg Config) (*Migrator, error) {
-	m, err := core.NewFromDSN(ctx, cfg.DSN, cfg.Dir)
+	if !core.TxMode(cfg.TxMode).Valid() {
+		return nil, fmt.Errorf("unknown tx mode %q", cfg.TxMode)
+	}
+	m, err := core.NewFromDSN(ctx, cfg.DSN, cfg.Dir, storeOptions(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(m, cfg), nil
+}
+
+// Returns a Migrator that works through the application's own pool, with
+// its limits, credentials and tracing wrappers; cfg.DSN is ignored.
+// The dialect is cfg.Driver or else guessed from db's driver (lib/pq, pgx,
+// go-sql-driver/mysql, go-sqlite3); MySQL pools need parseTime=true.
+// Close leaves db open.
+func NewWithDB(ctx context.Context, db *sql.DB, cfg Config) (*Migrator, error) {
+	if !core.TxMode(cfg.TxMode).Valid() {
+		return nil, fmt.Errorf("unknown tx mode %q", cfg.TxMode)
+	}
+	dialect, err := sqlstorage.DialectForDB(cfg.Driver, db)
+	if err != nil {
+		return nil, err
+	}
+	store, err := sqlstorage.NewWithDB(ctx, db, dialect, storeOptions(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(core.New(store, cfg.Dir), cfg), nil
+}
+
+// Returns a Migrator that works through the application's pgx pool
+// (with its TLS, credentials and limits) over the pgx driver.
+// cfg.DSN and cfg.Driver are ignored; Close leaves the pool open.
+func NewWithPool(ctx context.Context, pool *pgxpool.Pool, cfg Config) (*Migrator, error) {
+	if !core.TxMode(cfg.TxMode).Valid() {
+		return nil, fmt.Errorf("unknown tx mode %q", cfg.TxMode)
+	}
+	// Closing this *sql.DB returns its connections to the pool
+	// but does not close the pool itself.
+	db := sqlx.NewDb(stdlib.OpenDBFromPool(pool), sqlstorage.PGX.DriverName())
+	store, err := sqlstorage.Open(ctx, db, sqlstorage.PGX, storeOptions(cfg))
 	if err != nil {
+		_ = db.Close()
 		return nil, err
 	}
-	return &Migrator{m: m}, nil
+	return newMigrator(core.New(store, cfg.Dir), cfg), nil
 }
 
-// Closes the connection to the database.
+func storeOptions(cfg Config) sqlstorage.Options {
+	return sqlstorage.Options{
+		Driver:      cfg.Driver,
+		LockTimeout: cfg.LockTimeout,
+		TryLock:     cfg.TryLock,
+		OnLockWait:  cfg.OnLockWait,
+		Table:       cfg.Table,
+		Schema:      cfg.Schema,
+		LockKey:     cfg.LockKey,
+		ToolVersion: cfg.ToolVersion,
+	}
+}
+
+func newMigrator(m *core.Migrator, cfg Config) *Migrator {
+	m.WithOptions(core.Options{
+		FailOnDrift:    cfg.FailOnDrift,
+		AllowMissing:   cfg.AllowMissing,
+		AllowRemoveAll: cfg.AllowRemoveAll,
+		TxMode:         core.TxMode(cfg.TxMode),
+		GoMigrations:   registered(),
+		FS:             cfg.FS,
+		DryRun:         cfg.DryRun,
+		Warn:           cfg.OnWarning,
+	})
+	return &Migrator{m: m}
+}
+
+// Closes the connection to the database, unless it came from NewWithDB.
 func (m *Migrator) Close() error { return m.m.Close() }
 
 // Applies all migrations that have not yet been applied.
-func (m *Migrator) Up(ctx context.Context) error { return m.m.Up(ctx) }
+// Returns the applied migrations.
+func (m *Migrator) Up(ctx context.Context) ([]Step, error) {
+	return convertSteps(m.m.Up(ctx))
+}
+
+// Applies pending migrations up to and including version.
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]Step, error) {
+	return convertSteps(m.m.UpTo(ctx, version))
+}
+
+// Records every file up to and including version as applied without
+// running its SQL, for databases whose schema predates gomigrator.
+// Returns the migrations recorded.
+func (m *Migrator) Baseline(ctx context.Context, version int64) ([]Step, error) {
+	return convertSteps(m.m.Baseline(ctx, version))
+}
+
+// Applies only the oldest pending migration.
+// Returns no steps if everything is already applied.
+func (m *Migrator) UpByOne(ctx context.Context) ([]Step, error) {
+	return convertSteps(m.m.UpByOne(ctx))
+}
 
 // Rolls back the latest applied migration.
-func (m *Migrator) Down(ctx context.Context) error { return m.m.Down(ctx) }
+// Returns no steps if nothing is applied.
+func (m *Migrator) Down(ctx context.Context) ([]Step, error) {
+	return convertSteps(m.m.Down(ctx))
+}
+
+// Rolls back the n latest applied migrations under the migration lock,
+// grouped into transactions by Config.TxMode; NoTransaction files run
+// outside of one. Fails with *PartialError if some were already committed.
+func (m *Migrator) DownN(ctx context.Context, n int) ([]Step, error) {
+	return convertSteps(m.m.DownN(ctx, n))
+}
+
+// Rolls back every applied migration under the migration lock, grouped
+// into transactions as DownN does.
+func (m *Migrator) Reset(ctx context.Context) ([]Step, error) {
+	return convertSteps(m.m.Reset(ctx))
+}
 
-// Redo = Down + Up of the last migration, in a single transaction.
-func (m *Migrator) Redo(ctx context.Context) error { return m.m.Redo(ctx) }
+// Rolls back every applied migration newer than version, newest first,
+// grouped into transactions as DownN does. Version 0 rolls back everything.
+func (m *Migrator) DownTo(ctx context.Context, version int64) ([]Step, error) {
+	return convertSteps(m.m.DownTo(ctx, version))
+}
+
+// Redo = Down + Up of the last migration, in one transaction unless
+// its file is NoTransaction. Returns the down and up steps.
+func (m *Migrator) Redo(ctx context.Context) ([]Step, error) {
+	return convertSteps(m.m.Redo(ctx))
+}
 
 // Returns sorted migration statuses.
 func (m *Migrator) Status(ctx context.Context) ([]StatusEntry, error) {
@@ -53,15 +387,147 @@ func (m *Migrator) Status(ctx context.Context) ([]StatusEntry, error) {
 	}
 	statuses := make([]StatusEntry, len(internalStatuses))
 	for i, s := range internalStatuses {
-		statuses[i] = StatusEntry{
-			Version:   s.Version,
-			IsApplied: s.IsApplied,
-		}
+		statuses[i] = convertStatus(s)
 	}
 	return statuses, nil
 }
 
+// Returns every applied migration whose file no longer matches
+// the checksum recorded when it was applied.
+func (m *Migrator) Validate(ctx context.Context) ([]Drift, error) {
+	internalDrifts, err := m.m.Validate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	drifts := make([]Drift, len(internalDrifts))
+	for i, d := range internalDrifts {
+		drifts[i] = Drift(d)
+	}
+	return drifts, nil
+}
+
+// Returns every up, down, redo, force and baseline event, oldest first,
+// including migrations that were rolled back since.
+func (m *Migrator) History(ctx context.Context) ([]HistoryEntry, error) {
+	internalHistory, err := m.m.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]HistoryEntry, len(internalHistory))
+	for i, h := range internalHistory {
+		history[i] = HistoryEntry{
+			ID:          h.ID,
+			Version:     h.Version,
+			Name:        h.Name,
+			Kind:        EventKind(h.Kind),
+			Direction:   Direction(h.Direction),
+			Checksum:    h.Checksum,
+			Duration:    h.Duration,
+			OSUser:      h.OSUser,
+			Hostname:    h.Hostname,
+			ToolVersion: h.ToolVersion,
+			At:          h.At,
+		}
+	}
+	return history, nil
+}
+
+// Records version as applied (it must have a file) or removes its record,
+// without running any SQL. Meant for fixing the history by hand. Forcing
+// an applied version applied again, or a version without a record
+// unapplied, changes nothing, and no step is returned.
+func (m *Migrator) Force(ctx context.Context, version int64, applied bool) ([]Step, error) {
+	return convertSteps(m.m.Force(ctx, version, applied))
+}
+
+// Returns what Repair would change right now, without changing anything.
+func (m *Migrator) RepairPlan(ctx context.Context) (RepairReport, error) {
+	return convertRepair(m.m.RepairPlan(ctx))
+}
+
+// Removes records whose files no longer exist and stores the current
+// checksum of every applied file, appending a repair event to the history
+// for each. Refuses to run without any migrations, and to remove every
+// record unless Config.AllowRemoveAll is set. Returns what was changed.
+func (m *Migrator) Repair(ctx context.Context) (RepairReport, error) {
+	return convertRepair(m.m.Repair(ctx))
+}
+
+// Returns, in execution order, what cmd would execute right now, with the
+// full SQL of every step, without touching the database. version is the
+// target of CommandUpTo and CommandDownTo and is ignored otherwise.
+func (m *Migrator) Plan(ctx context.Context, cmd Command, version int64) ([]PlanStep, error) {
+	internalPlan, err := m.m.Plan(ctx, core.Command(cmd), version)
+	if err != nil {
+		return nil, err
+	}
+	plan := make([]PlanStep, len(internalPlan))
+	for i, p := range internalPlan {
+		plan[i] = PlanStep{
+			Version:       p.Version,
+			Name:          p.Name,
+			Path:          p.Path,
+			Direction:     Direction(p.Direction),
+			NoTransaction: p.NoTransaction,
+			SQL:           p.SQL,
+		}
+	}
+	return plan, nil
+}
+
 // Returns the highest applied version or 0 if none.
 func (m *Migrator) DBVersion(ctx context.Context) (int64, error) {
 	return m.m.DBVersion(ctx)
 }
+
+func convertSteps(internalSteps []core.Step, err error) ([]Step, error) {
+	steps := make([]Step, len(internalSteps))
+	for i, s := range internalSteps {
+		steps[i] = Step{
+			Version:   s.Version,
+			Name:      s.Name,
+			Direction: Direction(s.Direction),
+		}
+	}
+	return steps, convertErr(err)
+}
+
+func convertStatus(s core.StatusEntry) StatusEntry {
+	return StatusEntry{
+		Version:   s.Version,
+		Name:      s.Name,
+		Path:      s.Path,
+		State:     State(s.State),
+		IsApplied: s.IsApplied,
+		AppliedAt: s.AppliedAt,
+
+		HasRunInfo:  s.HasRunInfo,
+		Duration:    s.Duration,
+		AppliedBy:   s.AppliedBy,
+		ToolVersion: s.ToolVersion,
+	}
+}
+
+func convertRepair(internal core.RepairReport, err error) (RepairReport, error) {
+	if err != nil {
+		return RepairReport{}, err
+	}
+	var report RepairReport
+	for _, e := range internal.Removed {
+		report.Removed = append(report.Removed, convertStatus(e))
+	}
+	for _, d := range internal.Updated {
+		report.Updated = append(report.Updated, Drift(d))
+	}
+	return report, nil
+}
+
+// Turns internal errors into their public counterparts.
+func convertErr(err error) error {
+	var pe *core.PartialError
+	if errors.As(err, &pe) {
+		committed, _ := convertSteps(pe.Committed, nil)
+		return &PartialError{Committed: committed, Err: pe.Err}
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+package gomigrator
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hilltracer/gomigrator/internal/sqlstorage"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePostgres is just enough of a Postgres server for pgx in simple
+// protocol mode: it answers each query with answer(sql) and reports every
+// client that ends its session with Terminate.
+type fakePostgres struct {
+	ln         net.Listener
+	answer     func(sql string) []pgproto3.BackendMessage
+	terminated chan struct{}
+}
+
+func newFakePostgres(t *testing.T, answer func(sql string) []pgproto3.BackendMessage) *fakePostgres {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakePostgres{ln: ln, answer: answer, terminated: make(chan struct{}, 16)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakePostgres) dsn() string {
+	return "postgres://u@" + f.ln.Addr().String() + "/app?sslmode=disable&default_query_exec_mode=simple_protocol"
+}
+
+func (f *fakePostgres) serve(conn net.Conn) {
+	defer conn.Close()
+	be := pgproto3.NewBackend(conn, conn)
+	if _, err := be.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	be.Send(&pgproto3.AuthenticationOk{})
+	for k, v := range map[string]string{
+		"server_version": "16.0", "client_encoding": "UTF8", "standard_conforming_strings": "on",
+	} {
+		be.Send(&pgproto3.ParameterStatus{Name: k, Value: v})
+	}
+	be.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if be.Flush() != nil {
+		return
+	}
+
+	for {
+		msg, err := be.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Terminate:
+			f.terminated <- struct{}{}
+			return
+		case *pgproto3.Query:
+			for _, m := range f.answer(msg.String) {
+				be.Send(m)
+			}
+			be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if be.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// Returns a result set of one text column per name.
+func rows(names []string, values ...[]string) []pgproto3.BackendMessage {
+	desc := &pgproto3.RowDescription{}
+	for _, n := range names {
+		desc.Fields = append(desc.Fields, pgproto3.FieldDescription{
+			Name: []byte(n), DataTypeOID: 25, DataTypeSize: -1, TypeModifier: -1,
+		})
+	}
+	res := []pgproto3.BackendMessage{desc}
+	for _, v := range values {
+		row := &pgproto3.DataRow{}
+		for _, cell := range v {
+			row.Values = append(row.Values, []byte(cell))
+		}
+		res = append(res, row)
+	}
+	return append(res, &pgproto3.CommandComplete{CommandTag: []byte("SELECT " + strconv.Itoa(len(values)))})
+}
+
+// Answers what NewWithPool and an Up with nothing to apply ask for;
+// unlocking fails as if the session was lost.
+func upToDateServer(sql string) []pgproto3.BackendMessage {
+	switch {
+	case strings.HasPrefix(sql, "SELECT version FROM"):
+		return rows([]string{"version"}, []string{strconv.Itoa(sqlstorage.MetaVersion)})
+	case strings.HasPrefix(sql, "SELECT version, is_applied FROM"):
+		return rows([]string{"version", "is_applied"})
+	case strings.HasPrefix(sql, "SELECT pg_advisory_lock"):
+		return rows([]string{"pg_advisory_lock"}, []string{""})
+	case strings.HasPrefix(sql, "SELECT pg_advisory_unlock"):
+		return []pgproto3.BackendMessage{&pgproto3.ErrorResponse{
+			Severity: "ERROR", Code: "57P01", Message: "terminating connection",
+		}}
+	}
+	return []pgproto3.BackendMessage{&pgproto3.CommandComplete{CommandTag: []byte("CREATE TABLE")}}
+}
+
+func TestNewWithPool_DestroysConnWhenUnlockFails(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakePostgres(t, upToDateServer)
+	pool, err := pgxpool.New(ctx, srv.dsn())
+	require.NoError(t, err)
+	defer pool.Close()
+
+	m, err := NewWithPool(ctx, pool, Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	// the session still holding the lock must not go back to the pool
+	select {
+	case <-srv.terminated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection that failed to unlock was returned to the pool")
+	}
+	require.Eventually(t, func() bool { return pool.Stat().TotalConns() == 0 },
+		5*time.Second, 10*time.Millisecond)
+
+	// Close leaves the application's pool usable
+	require.NoError(t, m.Close())
+	conn, err := pool.Acquire(ctx)
+	require.NoError(t, err)
+	conn.Release()
+}
+
+func TestNewWithPool_RejectsUnknownTxMode(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), "postgres://u@127.0.0.1:1/app")
+	require.NoError(t, err)
+	defer pool.Close()
+
+	_, err = NewWithPool(context.Background(), pool, Config{TxMode: "bogus"})
+	require.ErrorContains(t, err, "unknown tx mode")
+}
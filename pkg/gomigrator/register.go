@@ -0,0 +1,49 @@
+package gomigrator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/hilltracer/gomigrator/internal/parser"
+)
+
+// Body of a migration written in Go. It runs inside the same
+// transaction and advisory lock as the SQL migrations.
+type MigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+var (
+	registryMu sync.Mutex
+	registry   = map[int64]parser.Migration{}
+)
+
+// Register adds a Go migration that every Migrator created afterwards
+// merges with the SQL files by version. It is meant to be called from init
+// functions and panics if the version is already registered or up is nil.
+// down may be nil when the migration cannot be rolled back.
+func Register(version int64, name string, up, down MigrationFunc) {
+	if up == nil {
+		panic(fmt.Sprintf("gomigrator: Register %d_%s: up is nil", version, name))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[version]; dup {
+		panic(fmt.Sprintf("gomigrator: Register called twice for version %d", version))
+	}
+	mig := parser.Migration{Version: version, Name: name, UpFn: parser.GoFunc(up)}
+	if down != nil {
+		mig.DownFn = parser.GoFunc(down)
+	}
+	registry[version] = mig
+}
+
+func registered() []parser.Migration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	res := make([]parser.Migration, 0, len(registry))
+	for _, mig := range registry {
+		res = append(res, mig)
+	}
+	return res
+}